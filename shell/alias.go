@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var aliases map[string]string = map[string]string{}
+
+// expandAlias replaces the first word of input with the alias it names. The
+// word produced by an expansion is looked up again, except for aliases that
+// were already expanded so that `alias ls='ls -F'` does not loop forever
+func expandAlias(input string) string {
+	expanded := map[string]bool{}
+	for {
+		trimmed := strings.TrimLeft(input, " \t")
+		end := strings.IndexAny(trimmed, " \t|;&<>")
+		if end == -1 {
+			end = len(trimmed)
+		}
+		word := trimmed[:end]
+		value, ok := aliases[word]
+		if !ok || expanded[word] {
+			return input
+		}
+		expanded[word] = true
+		input = value + trimmed[end:]
+	}
+}
+
+func aliasBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	if len(argsParts) == 0 || (len(argsParts) == 1 && argsParts[0] == "-p") {
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(outputWriter, "alias "+name+"="+quoteAliasValue(aliases[name]))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range argsParts {
+		name, value, isAssignment := strings.Cut(arg, "=")
+		if isAssignment {
+			if name == "" || strings.ContainsAny(name, " \t/$`'\"\\|;&<>()") {
+				fmt.Fprintln(errWriter, "alias: `"+name+"': invalid alias name")
+				status = 1
+				continue
+			}
+			aliases[name] = value
+			continue
+		}
+		value, ok := aliases[name]
+		if !ok {
+			fmt.Fprintln(errWriter, "alias: "+name+": not found")
+			status = 1
+			continue
+		}
+		fmt.Fprintln(outputWriter, "alias "+name+"="+quoteAliasValue(value))
+	}
+	return status
+}
+
+func unaliasBuiltin(argsParts []string, errWriter io.Writer) int {
+	if len(argsParts) == 0 {
+		fmt.Fprintln(errWriter, "unalias: usage: unalias [-a] name [name ...]")
+		return 2
+	}
+	if argsParts[0] == "-a" {
+		clear(aliases)
+		return 0
+	}
+	status := 0
+	for _, name := range argsParts {
+		if _, ok := aliases[name]; !ok {
+			fmt.Fprintln(errWriter, "unalias: "+name+": not found")
+			status = 1
+			continue
+		}
+		delete(aliases, name)
+	}
+	return status
+}
+
+// quoteAliasValue single quotes value so that the output of alias can be
+// read back in as input
+func quoteAliasValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,231 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errArithmetic = errors.New("syntax error in expression")
+var errDivisionByZero = errors.New("division by 0")
+var errArithRecursion = errors.New("expression recursion level exceeded")
+
+// arithBinaryPrecedence orders the binary operators of an arithmetic
+// expression, loosest first
+var arithBinaryPrecedence [][]string = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// arithParser evaluates integer expressions as used for array subscripts
+// and variables declared with -i. Names stand for the value of the
+// variable, itself evaluated as an expression, and unset or empty ones are 0
+type arithParser struct {
+	expr  string
+	pos   int
+	depth int
+}
+
+func (ap *arithParser) skipSpace() {
+	for ap.pos < len(ap.expr) && strings.IndexByte(" \t\n", ap.expr[ap.pos]) != -1 {
+		ap.pos++
+	}
+}
+
+// operator consumes op if it comes next, without mistaking the start of a
+// longer operator such as << or && for it
+func (ap *arithParser) operator(op string) bool {
+	ap.skipSpace()
+	if !strings.HasPrefix(ap.expr[ap.pos:], op) {
+		return false
+	}
+	if len(op) == 1 && ap.pos+1 < len(ap.expr) {
+		next := ap.expr[ap.pos+1]
+		if next == op[0] && strings.IndexByte("&|<>", next) != -1 {
+			return false
+		}
+		if next == '=' && strings.IndexByte("<>!", op[0]) != -1 {
+			return false
+		}
+	}
+	ap.pos += len(op)
+	return true
+}
+
+func (ap *arithParser) parseBinary(level int) (int64, error) {
+	if level == len(arithBinaryPrecedence) {
+		return ap.parseUnary()
+	}
+	left, err := ap.parseBinary(level + 1)
+	for err == nil {
+		matched := ""
+		for _, op := range arithBinaryPrecedence[level] {
+			if ap.operator(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			break
+		}
+		var right int64
+		right, err = ap.parseBinary(level + 1)
+		if err != nil {
+			break
+		}
+		left, err = applyArithOperator(left, matched, right)
+	}
+	return left, err
+}
+
+func applyArithOperator(left int64, op string, right int64) (int64, error) {
+	boolValue := func(b bool) int64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch op {
+	case "||":
+		return boolValue(left != 0 || right != 0), nil
+	case "&&":
+		return boolValue(left != 0 && right != 0), nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&":
+		return left & right, nil
+	case "==":
+		return boolValue(left == right), nil
+	case "!=":
+		return boolValue(left != right), nil
+	case "<=":
+		return boolValue(left <= right), nil
+	case ">=":
+		return boolValue(left >= right), nil
+	case "<":
+		return boolValue(left < right), nil
+	case ">":
+		return boolValue(left > right), nil
+	case "<<":
+		return left << uint64(right), nil
+	case ">>":
+		return left >> uint64(right), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	}
+	if right == 0 {
+		return 0, errDivisionByZero
+	}
+	if op == "/" {
+		return left / right, nil
+	}
+	return left % right, nil
+}
+
+func (ap *arithParser) parseUnary() (int64, error) {
+	switch {
+	case ap.operator("!"):
+		value, err := ap.parseUnary()
+		if value == 0 {
+			return 1, err
+		}
+		return 0, err
+	case ap.operator("~"):
+		value, err := ap.parseUnary()
+		return ^value, err
+	case ap.operator("-"):
+		value, err := ap.parseUnary()
+		return -value, err
+	case ap.operator("+"):
+		return ap.parseUnary()
+	}
+	return ap.parseOperand()
+}
+
+func (ap *arithParser) parseOperand() (int64, error) {
+	ap.skipSpace()
+	if ap.pos >= len(ap.expr) {
+		return 0, errArithmetic
+	}
+	if ap.operator("(") {
+		value, err := ap.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+		if !ap.operator(")") {
+			return 0, errArithmetic
+		}
+		return value, nil
+	}
+	start := ap.pos
+	for ap.pos < len(ap.expr) && isArithWordChar(ap.expr[ap.pos]) {
+		ap.pos++
+	}
+	word := ap.expr[start:ap.pos]
+	switch {
+	case word == "":
+		return 0, errArithmetic
+	case word[0] >= '0' && word[0] <= '9':
+		value, err := parseIntArg(word)
+		if err != nil {
+			return 0, errArithmetic
+		}
+		return value, nil
+	case !isValidVarName(word):
+		return 0, errArithmetic
+	}
+	if ap.depth > 100 {
+		return 0, errArithRecursion
+	}
+	nested := &arithParser{expr: lookupVar(word), depth: ap.depth + 1}
+	return nested.evaluate()
+}
+
+func isArithWordChar(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+func (ap *arithParser) evaluate() (int64, error) {
+	if strings.TrimSpace(ap.expr) == "" {
+		return 0, nil
+	}
+	value, err := ap.parseBinary(0)
+	if err == nil {
+		ap.skipSpace()
+		if ap.pos < len(ap.expr) {
+			err = errArithmetic
+		}
+	}
+	return value, err
+}
+
+// evalArithmetic evaluates expr, reporting errors prefixed by the expression
+// the way the shell prints them
+func evalArithmetic(expr string) (int64, error) {
+	value, err := (&arithParser{expr: expr}).evaluate()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", strings.TrimSpace(expr), err)
+	}
+	return value, nil
+}
+
+// formatArithmetic is evalArithmetic for callers that store the result
+func formatArithmetic(expr string) (string, error) {
+	value, err := evalArithmetic(expr)
+	return strconv.FormatInt(value, 10), err
+}
@@ -0,0 +1,537 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shellArray is an indexed or associative array variable. Indexed arrays
+// may be sparse, so both kinds store their elements by subscript
+type shellArray struct {
+	associative bool
+	elements    map[string]string
+	// order holds the subscripts of an associative array in the order they
+	// were first assigned
+	order []string
+}
+
+// shellArrays holds the array variables, which are never exported
+var shellArrays map[string]*shellArray = map[string]*shellArray{}
+
+// pipeStatus holds the exit status of every command of the last pipeline
+var pipeStatus []int = []int{0}
+
+func newShellArray(associative bool) *shellArray {
+	return &shellArray{associative: associative, elements: map[string]string{}}
+}
+
+// newIndexedArray builds an indexed array holding values from index 0
+func newIndexedArray(values []string) *shellArray {
+	array := newShellArray(false)
+	for i, value := range values {
+		array.set(strconv.Itoa(i), value)
+	}
+	return array
+}
+
+// keys returns the subscripts in ascending order for an indexed array and
+// in assignment order for an associative one
+func (sa *shellArray) keys() []string {
+	if sa.associative {
+		return slices.Clone(sa.order)
+	}
+	indices := make([]int, 0, len(sa.elements))
+	for key := range sa.elements {
+		index, _ := strconv.Atoi(key)
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	keys := make([]string, len(indices))
+	for i, index := range indices {
+		keys[i] = strconv.Itoa(index)
+	}
+	return keys
+}
+
+func (sa *shellArray) values() []string {
+	keys := sa.keys()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = sa.elements[key]
+	}
+	return values
+}
+
+func (sa *shellArray) get(key string) (string, bool) {
+	value, ok := sa.elements[key]
+	return value, ok
+}
+
+func (sa *shellArray) set(key, value string) {
+	if _, exists := sa.elements[key]; !exists && sa.associative {
+		sa.order = append(sa.order, key)
+	}
+	sa.elements[key] = value
+}
+
+// nextIndex is where += appends to an indexed array: one past the highest
+// index in use
+func (sa *shellArray) nextIndex() int {
+	next := 0
+	for key := range sa.elements {
+		if index, err := strconv.Atoi(key); err == nil && index >= next {
+			next = index + 1
+		}
+	}
+	return next
+}
+
+// getArray looks up an array variable. DIRSTACK, PIPESTATUS and
+// BASH_REMATCH are built from the shell's own state on every lookup
+func getArray(name string) (*shellArray, bool) {
+	switch name {
+	case "DIRSTACK":
+		return newIndexedArray(directoryStack()), true
+	case "BASH_REMATCH":
+		return newIndexedArray(bashRematch), true
+	case "PIPESTATUS":
+		statuses := make([]string, len(pipeStatus))
+		for i, status := range pipeStatus {
+			statuses[i] = strconv.Itoa(status)
+		}
+		return newIndexedArray(statuses), true
+	}
+	array, ok := shellArrays[name]
+	return array, ok
+}
+
+// arrayKeys returns the subscripts of name, where a plain variable has
+// just the subscript 0
+func arrayKeys(name string) []string {
+	if array, ok := getArray(name); ok {
+		return array.keys()
+	}
+	if _, ok := getVar(name); ok {
+		return []string{"0"}
+	}
+	return []string{}
+}
+
+// resolveSubscript turns a subscript into a key of array. Associative
+// arrays use it as it is while indexed ones evaluate it arithmetically,
+// with negative indices counting back from the end
+func resolveSubscript(array *shellArray, subscript string) (string, error) {
+	if array.associative {
+		return subscript, nil
+	}
+	index, err := evalArithmetic(subscript)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 {
+		index += int64(array.nextIndex())
+		if index < 0 {
+			return "", fmt.Errorf("%s: %w", subscript, errBadSubscript)
+		}
+	}
+	return strconv.FormatInt(index, 10), nil
+}
+
+// arrayFor returns the array stored in name, turning a plain variable into
+// an indexed array holding its value at index 0
+func arrayFor(name string, associative bool) *shellArray {
+	if array, ok := shellArrays[name]; ok {
+		return array
+	}
+	array := newShellArray(associative)
+	if value, ok := getVar(name); ok {
+		array.set("0", value)
+		removeScalar(name)
+	}
+	shellArrays[name] = array
+	return array
+}
+
+func setArrayElement(name, subscript, value string, appending bool) error {
+	array := arrayFor(name, false)
+	key, err := resolveSubscript(array, subscript)
+	if err != nil {
+		return err
+	}
+	old, _ := array.get(key)
+	value, err = shellVarAttrs[name].convert(old, value, appending)
+	if err != nil {
+		return err
+	}
+	array.set(key, value)
+	return nil
+}
+
+// splitElementAssignment recognises the [subscript]=value form of an
+// element inside name=(...)
+func splitElementAssignment(word shellWord) (string, string, bool, bool) {
+	if word.text == "" || word.text[0] != '[' || word.quoted[0] {
+		return "", "", false, false
+	}
+	for i := 1; i < len(word.text); i++ {
+		if word.text[i] != ']' || word.quoted[i] {
+			continue
+		}
+		rest := word.text[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "="):
+			return word.text[1:i], rest[1:], false, true
+		case strings.HasPrefix(rest, "+="):
+			return word.text[1:i], rest[2:], true, true
+		}
+		break
+	}
+	return "", "", false, false
+}
+
+// assignCompound assigns name=(...) given the text between the parentheses.
+// Each element is either a word, stored at the index after the previous
+// element, or [subscript]=value
+func assignCompound(name, body string, appending bool) error {
+	existing, exists := shellArrays[name]
+	array := newShellArray(exists && existing.associative)
+	if appending {
+		array = arrayFor(name, false)
+	}
+	attrs := shellVarAttrs[name]
+	next := array.nextIndex()
+	for _, word := range tokenizeWords(body, true) {
+		key := strconv.Itoa(next)
+		value := word.text
+		elementAppending := false
+		subscript, elementValue, elementAppend, ok := splitElementAssignment(word)
+		switch {
+		case ok:
+			var err error
+			if key, err = resolveSubscript(array, subscript); err != nil {
+				return err
+			}
+			value = elementValue
+			elementAppending = elementAppend
+		case array.associative:
+			return fmt.Errorf("%s: %s: %w", name, word.text, errAssocSubscript)
+		}
+		old, _ := array.get(key)
+		value, err := attrs.convert(old, value, elementAppending)
+		if err != nil {
+			return err
+		}
+		array.set(key, value)
+		if !array.associative {
+			index, _ := strconv.Atoi(key)
+			next = index + 1
+		}
+	}
+	removeScalar(name)
+	shellArrays[name] = array
+	return nil
+}
+
+// assignArray replaces name with an indexed array of values, as read -a does
+func assignArray(name string, values []string) error {
+	if shellVarAttrs[name].readonly {
+		return fmt.Errorf("%s: %w", name, errReadonly)
+	}
+	array := newShellArray(false)
+	for i, value := range values {
+		value, err := shellVarAttrs[name].convert("", value, false)
+		if err != nil {
+			return err
+		}
+		array.set(strconv.Itoa(i), value)
+	}
+	removeScalar(name)
+	shellArrays[name] = array
+	return nil
+}
+
+// varAttrs are the attributes declare gives a variable besides its kind.
+// Whether it is exported follows from it being in the environment
+type varAttrs struct {
+	integer  bool
+	readonly bool
+	lower    bool
+	upper    bool
+}
+
+// shellVarAttrs holds the attributes of every variable declare has seen,
+// including ones declared without a value
+var shellVarAttrs map[string]varAttrs = map[string]varAttrs{}
+
+// convert applies the attributes to a value being assigned, combining it
+// with the old value when appending
+func (attrs varAttrs) convert(old, value string, appending bool) (string, error) {
+	if attrs.integer {
+		if appending {
+			value = old + "+(" + value + ")"
+		}
+		return formatArithmetic(value)
+	}
+	if appending {
+		value = old + value
+	}
+	switch {
+	case attrs.lower:
+		return strings.ToLower(value), nil
+	case attrs.upper:
+		return strings.ToUpper(value), nil
+	}
+	return value, nil
+}
+
+// declareQuote quotes a value the way declare -p prints it
+func declareQuote(value string) string {
+	var res strings.Builder
+	res.WriteByte('"')
+	for _, char := range value {
+		if strings.ContainsRune("\"\\$`", char) {
+			res.WriteByte('\\')
+		}
+		res.WriteRune(char)
+	}
+	res.WriteByte('"')
+	return res.String()
+}
+
+// declarationFlags returns the attribute letters declare -p shows for name
+func declarationFlags(name string) string {
+	flags := ""
+	if array, ok := getArray(name); ok {
+		if array.associative {
+			flags += "A"
+		} else {
+			flags += "a"
+		}
+	}
+	attrs := shellVarAttrs[name]
+	if attrs.integer {
+		flags += "i"
+	}
+	if attrs.readonly {
+		flags += "r"
+	}
+	if _, exported := os.LookupEnv(name); exported {
+		flags += "x"
+	}
+	if attrs.lower {
+		flags += "l"
+	}
+	if attrs.upper {
+		flags += "u"
+	}
+	if flags == "" {
+		return "--"
+	}
+	return "-" + flags
+}
+
+// formatDeclaration returns name=value, or name=([key]="value" ...) for an
+// array, as declare prints variables. A variable that was declared without
+// a value is just its name
+func formatDeclaration(name string) (string, bool) {
+	if array, ok := getArray(name); ok {
+		var elements strings.Builder
+		for _, key := range array.keys() {
+			value, _ := array.get(key)
+			fmt.Fprintf(&elements, "[%s]=%s ", key, declareQuote(value))
+		}
+		text := elements.String()
+		if !array.associative {
+			text = strings.TrimSuffix(text, " ")
+		}
+		return name + "=(" + text + ")", true
+	}
+	if value, ok := getVar(name); ok {
+		return name + "=" + declareQuote(value), true
+	}
+	if _, declared := shellVarAttrs[name]; declared {
+		return name, true
+	}
+	return "", false
+}
+
+// hasAttributes reports whether name has every attribute in flags
+func hasAttributes(name string, flags map[rune]bool) bool {
+	shown := declarationFlags(name)
+	for flag := range flags {
+		if !strings.ContainsRune(shown, flag) {
+			return false
+		}
+	}
+	return true
+}
+
+// declaredNames returns the names of all variables, sorted
+func declaredNames() []string {
+	seen := map[string]bool{}
+	for name := range shellVars {
+		seen[name] = true
+	}
+	for name := range shellArrays {
+		seen[name] = true
+	}
+	for name := range shellVarAttrs {
+		seen[name] = true
+	}
+	for _, entry := range os.Environ() {
+		if name, _, found := strings.Cut(entry, "="); found && isValidVarName(name) {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// declareVariable gives the variable named by arg, which may be an
+// assignment, the attributes in set and takes away those in unset
+func declareVariable(arg string, set, unset map[rune]bool) error {
+	a, isAssignment := parseAssignment(arg, true)
+	name := a.name
+	if !isAssignment {
+		if !isValidVarName(arg) {
+			return fmt.Errorf("`%s': not a valid identifier", arg)
+		}
+		name = arg
+	}
+	attrs := shellVarAttrs[name]
+	if attrs.readonly && (isAssignment || unset['r']) {
+		return fmt.Errorf("%s: %w", name, errReadonly)
+	}
+	array, isArray := shellArrays[name]
+	switch {
+	case set['A'] && isArray && !array.associative:
+		return fmt.Errorf("%s: cannot convert indexed to associative array", name)
+	case set['a'] && isArray && array.associative:
+		return fmt.Errorf("%s: cannot convert associative to indexed array", name)
+	case set['A'] || set['a']:
+		arrayFor(name, set['A'])
+	}
+	for flag, enable := range map[rune]bool{'i': true, 'l': true, 'u': true} {
+		if unset[flag] {
+			enable = false
+		} else if !set[flag] {
+			continue
+		}
+		switch flag {
+		case 'i':
+			attrs.integer = enable
+		case 'l':
+			attrs.lower = enable
+			attrs.upper = attrs.upper && !enable
+		case 'u':
+			attrs.upper = enable
+			attrs.lower = attrs.lower && !enable
+		}
+	}
+	shellVarAttrs[name] = attrs
+	if isAssignment {
+		if err := a.apply(); err != nil {
+			return err
+		}
+	}
+	if value, ok := shellVars[name]; ok && set['x'] {
+		os.Setenv(name, value)
+		delete(shellVars, name)
+	}
+	if value, ok := os.LookupEnv(name); ok && unset['x'] {
+		shellVars[name] = value
+		os.Unsetenv(name)
+	}
+	if set['r'] {
+		attrs.readonly = true
+		shellVarAttrs[name] = attrs
+	}
+	return nil
+}
+
+// declareBuiltin implements declare and readonly, which is declare -r
+// restricted to the options that make sense for it
+func declareBuiltin(commandName string, argsParts []string, outputWriter, errWriter io.Writer) int {
+	allowed := "aAilprux"
+	usage := "declare: usage: declare [-aAilprux] [name[=value] ...]"
+	if commandName == "readonly" {
+		allowed = "aAp"
+		usage = "readonly: usage: readonly [-aAp] [name[=value] ...]"
+	}
+	set := map[rune]bool{}
+	unset := map[rune]bool{}
+	printing := false
+	args := argsParts
+	for len(args) > 0 && len(args[0]) > 1 && (args[0][0] == '-' || args[0][0] == '+') {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			if !strings.ContainsRune(allowed, flag) {
+				fmt.Fprintln(errWriter, commandName+": "+args[0][:1]+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, usage)
+				return 2
+			}
+			switch {
+			case flag == 'p':
+				printing = true
+			case args[0][0] == '-':
+				set[flag] = true
+			default:
+				unset[flag] = true
+			}
+		}
+		args = args[1:]
+	}
+	if commandName == "readonly" {
+		set['r'] = true
+	}
+
+	if len(args) == 0 {
+		// list the variables having all the given attributes, in a form
+		// that can be read back unless no option was given at all
+		for _, name := range declaredNames() {
+			if !hasAttributes(name, set) {
+				continue
+			}
+			declaration, ok := formatDeclaration(name)
+			if !ok {
+				continue
+			}
+			if printing || len(set) > 0 {
+				fmt.Fprintln(outputWriter, "declare "+declarationFlags(name)+" "+declaration)
+			} else {
+				fmt.Fprintln(outputWriter, declaration)
+			}
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		if printing {
+			declaration, ok := formatDeclaration(arg)
+			if !ok {
+				fmt.Fprintln(errWriter, commandName+": "+arg+": not found")
+				status = 1
+				continue
+			}
+			fmt.Fprintln(outputWriter, "declare "+declarationFlags(arg)+" "+declaration)
+			continue
+		}
+		if err := declareVariable(arg, set, unset); err != nil {
+			fmt.Fprintln(errWriter, commandName+": "+err.Error())
+			status = 1
+		}
+	}
+	return status
+}
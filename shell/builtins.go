@@ -0,0 +1,251 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Builtin is a command the shell carries out itself rather than running a
+// program for
+type Builtin interface {
+	Name() string
+	// Run carries out the command with the streams it was given and
+	// returns its exit status
+	Run(ctx context.Context, streams Streams, args []string) int
+	// Usage is the synopsis of the command and Help a short description
+	// of what it does, both shown by the help builtin
+	Usage() string
+	Help() string
+	// Complete offers completions for the argument being typed, or nil to
+	// leave it to the usual completion of command names
+	Complete(word string) [][]rune
+}
+
+// builtinCommand implements Builtin with plain functions, as the shell's
+// own builtins do
+type builtinCommand struct {
+	name     string
+	usage    string
+	help     string
+	run      func(ctx context.Context, streams Streams, args []string) int
+	complete func(word string) [][]rune
+}
+
+func (bc *builtinCommand) Name() string  { return bc.name }
+func (bc *builtinCommand) Usage() string { return bc.usage }
+func (bc *builtinCommand) Help() string  { return bc.help }
+
+func (bc *builtinCommand) Run(ctx context.Context, streams Streams, args []string) int {
+	return bc.run(ctx, streams, args)
+}
+
+func (bc *builtinCommand) Complete(word string) [][]rune {
+	if bc.complete == nil {
+		return nil
+	}
+	return bc.complete(word)
+}
+
+// builtins holds every builtin by name. `type`, `help`, `enable` and tab
+// completion all go by it, so a builtin only has to be registered here
+var builtins map[string]Builtin = map[string]Builtin{}
+
+func registerBuiltin(b Builtin) {
+	builtins[b.Name()] = b
+}
+
+// builtinNames returns the names of all builtins, sorted
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pathDirectories splits PATH, which for a builtin includes any assignment
+// in front of it
+func pathDirectories() []string {
+	return strings.Split(lookupVar("PATH"), ":")
+}
+
+// runBuiltin runs the builtin name with the streams of the command, which
+// in a pipeline are the pipes to the stages around it
+func runBuiltin(ctx context.Context, name string, streams Streams, args []string) int {
+	return builtins[name].Run(ctx, streams, args)
+}
+
+func init() {
+	for _, b := range []*builtinCommand{
+		{name: "echo", usage: "echo [-neE] [arg ...]", help: "Write arguments to the standard output.",
+			run: func(ctx context.Context, s Streams, args []string) int { return echoBuiltin(args, s.Stdout) }},
+		{name: "exit", usage: "exit [n]", help: "Exit the shell.",
+			run: func(ctx context.Context, s Streams, args []string) int { return exitBuiltin(args, s.Stderr) }},
+		{name: "type", usage: "type [-afptP] name [name ...]", help: "Display information about command type.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return typeBuiltin(args, s.Stdout, s.Stderr, pathDirectories())
+			}},
+		{name: "pwd", usage: "pwd [-LP]", help: "Print the name of the current working directory.",
+			run: func(ctx context.Context, s Streams, args []string) int { return pwdBuiltin(args, s.Stdout, s.Stderr) }},
+		{name: "cd", usage: "cd [-L|-P] [dir]", help: "Change the shell working directory.",
+			run: func(ctx context.Context, s Streams, args []string) int { return cdBuiltin(args, s.Stdout, s.Stderr) }},
+		{name: "history", usage: "history [n] or history -arw filename", help: "Display or manipulate the history list.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return historyBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "hash", usage: "hash [-lr] [-p pathname] [-dt] [name ...]", help: "Remember or display program locations.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return hashBuiltin(args, s.Stdout, s.Stderr, pathDirectories())
+			}},
+		{name: "alias", usage: "alias [-p] [name[=value] ... ]", help: "Define or display aliases.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return aliasBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "unalias", usage: "unalias [-a] name [name ...]", help: "Remove each NAME from the list of defined aliases.",
+			run: func(ctx context.Context, s Streams, args []string) int { return unaliasBuiltin(args, s.Stderr) }},
+		{name: "command", usage: "command [-pVv] command [arg ...]", help: "Execute a simple command or display information about commands.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return commandBuiltin(args, s.Stdout, s.Stderr, pathDirectories())
+			}},
+		{name: "builtin", usage: "builtin [shell-builtin [arg ...]]", help: "Execute shell builtins.",
+			// `builtin name` was already dispatched to name, only bare builtin gets here
+			run: func(ctx context.Context, s Streams, args []string) int { return 0 }},
+		{name: "enable", usage: "enable [-a] [-np] [name ...]", help: "Enable and disable shell builtins.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return enableBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "pushd", usage: "pushd [-n] [+N | -N | dir]", help: "Add directories to stack.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return pushdBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "popd", usage: "popd [-n] [+N | -N]", help: "Remove directories from stack.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return popdBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "dirs", usage: "dirs [-clpv] [+N] [-N]", help: "Display directory stack.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return dirsBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "z", usage: "z [-lrt] [dir ...]", help: "Jump to the most frecent directory matching every DIR.",
+			run:      func(ctx context.Context, s Streams, args []string) int { return zBuiltin(args, s.Stdout, s.Stderr) },
+			complete: completeFrecentDirs},
+		{name: "printf", usage: "printf [-v var] format [arguments]", help: "Formats and prints ARGUMENTS under control of the FORMAT.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return printfBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "shopt", usage: "shopt [-pqsu] [optname ...]", help: "Set and unset shell options.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return shoptBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "read", usage: "read [-rs] [-a array] [-d delim] [-n nchars] [-N nchars] [-p prompt] [-t timeout] [-u fd] [name ...]", help: "Read a line from the standard input and split it into fields.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return readBuiltin(ctx, args, s.Stdin, s.Stderr)
+			}},
+		{name: "test", usage: "test [expr]", help: "Evaluate conditional expression.",
+			run: func(ctx context.Context, s Streams, args []string) int { return testBuiltin("test", args, s.Stderr) }},
+		{name: "[", usage: "[ arg... ]", help: "Evaluate conditional expression.",
+			run: func(ctx context.Context, s Streams, args []string) int { return testBuiltin("[", args, s.Stderr) }},
+		{name: "declare", usage: "declare [-aAilprux] [name[=value] ...]", help: "Set variable values and attributes.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return declareBuiltin("declare", args, s.Stdout, s.Stderr)
+			}},
+		{name: "readonly", usage: "readonly [-aAp] [name[=value] ...]", help: "Mark shell variables as unchangeable.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return declareBuiltin("readonly", args, s.Stdout, s.Stderr)
+			}},
+		{name: "eval", usage: "eval [arg ...]", help: "Execute arguments as a shell command.",
+			run: func(ctx context.Context, s Streams, args []string) int { return evalBuiltin(args, s) }},
+		{name: "exec", usage: "exec [-c] [-a name] [command [argument ...]]", help: "Replace the shell with the given command.",
+			run: func(ctx context.Context, s Streams, args []string) int { return execBuiltin(args, s.Stderr) }},
+		{name: "timeout", usage: "timeout [-k duration] [-s signal] [--preserve-status] duration command [arg ...]", help: "Run a command with a time limit.",
+			run: func(ctx context.Context, s Streams, args []string) int { return timeoutBuiltin(args, s) }},
+		{name: "times", usage: "times", help: "Display the accumulated user and system times of the shell and its children.",
+			run: func(ctx context.Context, s Streams, args []string) int { return timesBuiltin(s.Stdout) }},
+		{name: "ulimit", usage: "ulimit [-HSa] [-c|-d|-f|-n|-s|-t|-u|-v] [limit]", help: "Modify shell resource limits.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return ulimitBuiltin(args, s.Stdout, s.Stderr)
+			}},
+		{name: "umask", usage: "umask [-S] [mode]", help: "Display or set file mode mask.",
+			run: func(ctx context.Context, s Streams, args []string) int { return umaskBuiltin(args, s.Stdout, s.Stderr) }},
+		{name: "kill", usage: "kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]", help: "Send a signal to a job.",
+			run: func(ctx context.Context, s Streams, args []string) int { return killBuiltin(args, s.Stdout, s.Stderr) }},
+		{name: "help", usage: "help [-ds] [pattern ...]", help: "Display information about builtin commands.",
+			run: func(ctx context.Context, s Streams, args []string) int {
+				return helpBuiltin(args, s.Stdout, s.Stderr)
+			}},
+	} {
+		registerBuiltin(b)
+	}
+}
+
+// helpBuiltin describes the builtins whose names match the patterns, or
+// lists the usage of all of them. -d gives a one line description and -s
+// only the usage
+func helpBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	short := false
+	synopsis := false
+	patterns := argsParts
+	for len(patterns) > 0 && strings.HasPrefix(patterns[0], "-") && len(patterns[0]) > 1 {
+		if patterns[0] == "--" {
+			patterns = patterns[1:]
+			break
+		}
+		for _, flag := range patterns[0][1:] {
+			switch flag {
+			case 'd':
+				short = true
+			case 's':
+				synopsis = true
+			default:
+				fmt.Fprintln(errWriter, "help: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "help: usage: help [-ds] [pattern ...]")
+				return 2
+			}
+		}
+		patterns = patterns[1:]
+	}
+	if len(patterns) == 0 {
+		fmt.Fprintln(outputWriter, "These shell commands are defined internally.  Type `help' to see this list.")
+		fmt.Fprintln(outputWriter, "Type `help name' to find out more about the function `name'.")
+		fmt.Fprintln(outputWriter)
+		for _, name := range builtinNames() {
+			marker := " "
+			if disabledBuiltins[name] {
+				// disabled builtins are starred as in bash
+				marker = "*"
+			}
+			fmt.Fprintln(outputWriter, marker+builtins[name].Usage())
+		}
+		return 0
+	}
+	status := 0
+	for _, pattern := range patterns {
+		found := false
+		for _, name := range builtinNames() {
+			if matched, _ := path.Match(pattern, name); !matched && !strings.HasPrefix(name, pattern) {
+				continue
+			}
+			found = true
+			b := builtins[name]
+			switch {
+			case short:
+				fmt.Fprintln(outputWriter, name+" - "+b.Help())
+			case synopsis:
+				fmt.Fprintln(outputWriter, name+": "+b.Usage())
+			default:
+				fmt.Fprintln(outputWriter, name+": "+b.Usage())
+				fmt.Fprintln(outputWriter, "    "+b.Help())
+			}
+		}
+		if !found {
+			fmt.Fprintln(errWriter, "help: no help topics match `"+pattern+"'.  Try `help help' or `man -k "+pattern+"' or `info "+pattern+"'.")
+			status = 1
+		}
+	}
+	return status
+}
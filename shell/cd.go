@@ -0,0 +1,250 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// initWorkingDir makes sure PWD describes the current directory when the
+// shell starts. An inherited PWD is kept if it still refers to the same
+// directory so that paths through symlinks are preserved
+func initWorkingDir() {
+	if logicalWorkingDir() == "" {
+		if workingDir, err := os.Getwd(); err == nil {
+			os.Setenv("PWD", workingDir)
+		}
+	}
+}
+
+// logicalWorkingDir returns $PWD if it is an absolute path to the current
+// directory, or an empty string when it can't be trusted
+func logicalWorkingDir() string {
+	pwd := os.Getenv("PWD")
+	if !filepath.IsAbs(pwd) {
+		return ""
+	}
+	pwdInfo, err := os.Stat(pwd)
+	if err != nil {
+		return ""
+	}
+	dotInfo, err := os.Stat(".")
+	if err != nil || !os.SameFile(pwdInfo, dotInfo) {
+		return ""
+	}
+	return pwd
+}
+
+func physicalWorkingDir() (string, error) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(workingDir)
+}
+
+// changeDirectory switches to dir and updates PWD and OLDPWD. A logical
+// change resolves .. against the path in PWD (so cd .. out of a symlinked
+// directory goes back where you came from) while a physical one resolves
+// all symlinks the way the kernel does
+func changeDirectory(dir string, physical bool) error {
+	oldPwd := logicalWorkingDir()
+	if oldPwd == "" {
+		oldPwd, _ = os.Getwd()
+	}
+	target := dir
+	if !physical {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(oldPwd, target)
+		}
+		target = filepath.Clean(target)
+	}
+	if err := os.Chdir(target); err != nil {
+		return err
+	}
+	newPwd := target
+	if physical {
+		var err error
+		if newPwd, err = physicalWorkingDir(); err != nil {
+			return err
+		}
+	}
+	os.Setenv("OLDPWD", oldPwd)
+	os.Setenv("PWD", newPwd)
+	recordDirectoryVisit()
+	return nil
+}
+
+// searchCdPath looks for dir inside each CDPATH entry and reports whether
+// the match should be printed, which is the case for any entry but "."
+func searchCdPath(dir string) (string, bool) {
+	cdPath := lookupVar("CDPATH")
+	if cdPath == "" || filepath.IsAbs(dir) || dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return dir, false
+	}
+	for _, entry := range strings.Split(cdPath, ":") {
+		base := entry
+		if base == "" {
+			base = "."
+		}
+		candidate := filepath.Join(base, dir)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			if entry == "" || entry == "." {
+				return dir, false
+			}
+			return candidate, true
+		}
+	}
+	return dir, false
+}
+
+// describeChdirError turns the error from os.Chdir into the message bash
+// would print for it
+func describeChdirError(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "No such file or directory"
+	case errors.Is(err, syscall.ENOTDIR):
+		return "Not a directory"
+	case errors.Is(err, fs.ErrPermission):
+		return "Permission denied"
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// expandTilde expands a leading ~ (the home directory), ~+ (PWD), ~- (OLDPWD)
+// or ~N, ~+N and ~-N (entries of the directory stack as numbered by dirs -v)
+// in word. Anything else is returned unchanged
+func expandTilde(word string) string {
+	if !strings.HasPrefix(word, "~") {
+		return word
+	}
+	slash := strings.IndexByte(word, '/')
+	if slash == -1 {
+		slash = len(word)
+	}
+	var expanded string
+	switch prefix := word[1:slash]; prefix {
+	case "":
+		expanded = lookupVar("HOME")
+		if expanded == "" {
+			expanded, _ = os.UserHomeDir()
+		}
+	case "+":
+		expanded = lookupVar("PWD")
+	case "-":
+		expanded = lookupVar("OLDPWD")
+	default:
+		entry, ok := directoryStackEntry(prefix)
+		if !ok {
+			return word
+		}
+		expanded = entry
+	}
+	if expanded == "" {
+		return word
+	}
+	return expanded + word[slash:]
+}
+
+func cdBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	physical := false
+	args := argsParts
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'P':
+				physical = true
+			case 'L':
+				physical = false
+			default:
+				fmt.Fprintln(errWriter, "cd: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "cd: usage: cd [-L|-P] [dir]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errWriter, "cd: too many arguments")
+		return 1
+	}
+	var dir string
+	printDir := false
+	switch {
+	case len(args) == 0:
+		dir = lookupVar("HOME")
+		if dir == "" {
+			fmt.Fprintln(errWriter, "cd: HOME not set")
+			return 1
+		}
+	case args[0] == "-":
+		dir = lookupVar("OLDPWD")
+		if dir == "" {
+			fmt.Fprintln(errWriter, "cd: OLDPWD not set")
+			return 1
+		}
+		printDir = true
+	default:
+		dir, printDir = searchCdPath(expandTilde(args[0]))
+	}
+	if err := changeDirectory(dir, physical); err != nil {
+		arg := dir
+		if len(args) > 0 {
+			arg = args[0]
+		}
+		fmt.Fprintln(errWriter, "cd: "+arg+": "+describeChdirError(err))
+		return 1
+	}
+	if printDir {
+		fmt.Fprintln(outputWriter, os.Getenv("PWD"))
+	}
+	return 0
+}
+
+func pwdBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	physical := false
+	for _, arg := range argsParts {
+		switch arg {
+		case "-P":
+			physical = true
+		case "-L":
+			physical = false
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Fprintln(errWriter, "pwd: "+arg+": invalid option")
+				fmt.Fprintln(errWriter, "pwd: usage: pwd [-LP]")
+				return 2
+			}
+			fmt.Fprintln(errWriter, "pwd takes no arguments but some were given")
+			return 1
+		}
+	}
+	workingDir := ""
+	if !physical {
+		workingDir = logicalWorkingDir()
+	}
+	if workingDir == "" {
+		var err error
+		if workingDir, err = physicalWorkingDir(); err != nil {
+			fmt.Fprintln(errWriter, "Error running command: "+err.Error())
+			return 1
+		}
+	}
+	fmt.Fprintln(outputWriter, workingDir)
+	return 0
+}
@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// defaultPath is searched by `command -p` so that standard utilities can be
+// found no matter what the user's PATH is set to
+const defaultPath string = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// disabledBuiltins holds the builtins turned off with `enable -n` so that a
+// PATH executable of the same name runs instead
+var disabledBuiltins map[string]bool = map[string]bool{}
+
+func isBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok && !disabledBuiltins[name]
+}
+
+// unwrapCommandPrefixes strips leading `command` and `builtin` words so the
+// command they name is dispatched directly. Aliases are already skipped as
+// only the first word of a command is alias expanded. `command -v` and
+// `command -V` are left alone since they describe rather than run
+func unwrapCommandPrefixes(cmdName string, cmdArgs []string) (string, []string, error) {
+	for len(cmdArgs) > 0 {
+		switch cmdName {
+		case "command":
+			usePath := false
+			args := cmdArgs
+			for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+				if args[0] == "--" {
+					args = args[1:]
+					break
+				}
+				if strings.ContainsAny(args[0], "vV") {
+					return cmdName, cmdArgs, nil
+				}
+				usePath = usePath || strings.Contains(args[0], "p")
+				args = args[1:]
+			}
+			if len(args) == 0 {
+				return cmdName, cmdArgs, nil
+			}
+			cmdName, cmdArgs = args[0], args[1:]
+			if usePath && !isBuiltin(cmdName) && !strings.Contains(cmdName, "/") {
+				if matches := findInPath(cmdName, strings.Split(defaultPath, ":"), false); len(matches) > 0 {
+					cmdName = matches[0]
+				}
+			}
+		case "builtin":
+			if !isBuiltin(cmdArgs[0]) {
+				return cmdArgs[0], cmdArgs[1:], errNotBuiltin
+			}
+			cmdName, cmdArgs = cmdArgs[0], cmdArgs[1:]
+		default:
+			return cmdName, cmdArgs, nil
+		}
+	}
+	return cmdName, cmdArgs, nil
+}
+
+// commandBuiltin only handles the describing forms `command -v` and
+// `command -V`, running a command through it is done by
+// unwrapCommandPrefixes before dispatch
+func commandBuiltin(argsParts []string, outputWriter, errWriter io.Writer, directories []string) int {
+	verbose := false
+	brief := false
+	names := argsParts
+	for len(names) > 0 && strings.HasPrefix(names[0], "-") && len(names[0]) > 1 {
+		if names[0] == "--" {
+			names = names[1:]
+			break
+		}
+		for _, flag := range names[0][1:] {
+			switch flag {
+			case 'v':
+				brief = true
+			case 'V':
+				verbose = true
+			case 'p':
+				directories = strings.Split(defaultPath, ":")
+			default:
+				fmt.Fprintln(errWriter, "command: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "command: usage: command [-pVv] command [arg ...]")
+				return 2
+			}
+		}
+		names = names[1:]
+	}
+	status := 0
+	for _, name := range names {
+		switch {
+		case verbose:
+			if !describeCommand(name, false, false, false, false, outputWriter, directories) {
+				fmt.Fprintln(errWriter, "command: "+name+": not found")
+				status = 1
+			}
+		case brief:
+			if value, ok := aliases[name]; ok {
+				fmt.Fprintln(outputWriter, "alias "+name+"="+quoteAliasValue(value))
+			} else if slices.Contains(shellKeywords, name) || isBuiltin(name) {
+				fmt.Fprintln(outputWriter, name)
+			} else if strings.Contains(name, "/") {
+				if _, err := resolveCommand(name, directories); err != nil {
+					status = 1
+					continue
+				}
+				fmt.Fprintln(outputWriter, name)
+			} else if matches := findInPath(name, directories, false); len(matches) > 0 {
+				fmt.Fprintln(outputWriter, matches[0])
+			} else {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+func enableBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	disable := false
+	listAll := false
+	names := argsParts
+	for len(names) > 0 && strings.HasPrefix(names[0], "-") && len(names[0]) > 1 {
+		for _, flag := range names[0][1:] {
+			switch flag {
+			case 'n':
+				disable = true
+			case 'a':
+				listAll = true
+			case 'p':
+				// printing is what happens without names anyway
+			default:
+				fmt.Fprintln(errWriter, "enable: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "enable: usage: enable [-a] [-np] [name ...]")
+				return 2
+			}
+		}
+		names = names[1:]
+	}
+	if len(names) == 0 {
+		for _, name := range builtinNames() {
+			switch {
+			case disabledBuiltins[name] && (disable || listAll):
+				fmt.Fprintln(outputWriter, "enable -n "+name)
+			case !disabledBuiltins[name] && (!disable || listAll):
+				fmt.Fprintln(outputWriter, "enable "+name)
+			}
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range names {
+		if _, ok := builtins[name]; !ok {
+			fmt.Fprintln(errWriter, "enable: "+name+": not a shell builtin")
+			status = 1
+			continue
+		}
+		if disable {
+			disabledBuiltins[name] = true
+		} else {
+			delete(disabledBuiltins, name)
+		}
+	}
+	return status
+}
@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// coprocess is a command started with coproc. The shell reads its output
+// from fds[0] and writes to its input through fds[1], as in NAME[0] and
+// NAME[1]
+type coprocess struct {
+	cmd  *exec.Cmd
+	fds  [2]int
+	done chan struct{}
+}
+
+// coprocesses holds the coprocesses started so far by name
+var coprocesses map[string]*coprocess = map[string]*coprocess{}
+
+// coprocFds holds the shell's ends of the coprocesses' pipes. Unlike the
+// descriptors opened by exec, commands don't inherit these and only get
+// them through redirections such as >&${COPROC[1]}
+var coprocFds map[int]*os.File = map[int]*os.File{}
+
+// isCoprocCommand reports whether input starts a coprocess
+func isCoprocCommand(input string) bool {
+	words := tokenizeWords(input, true)
+	return len(words) > 1 && words[0].text == "coproc" && !slices.Contains(words[0].quoted, true)
+}
+
+// coprocCommand starts the command of `coproc [NAME] command` in the
+// background with pipes to its stdin and from its stdout. As in bash a NAME
+// is only recognised in front of a group, the coprocess being COPROC
+// otherwise
+func coprocCommand(input string, errWriter io.Writer) int {
+	command := strings.TrimSpace(strings.TrimSpace(input)[len("coproc"):])
+	name := "COPROC"
+	if first, rest, found := strings.Cut(command, " "); found && isValidVarName(first) && isGroupCommand(rest) {
+		name = first
+		command = strings.TrimSpace(rest)
+	}
+	if old, ok := coprocesses[name]; ok {
+		select {
+		case <-old.done:
+		default:
+			fmt.Fprintf(errWriter, "coproc: warning: execute_coproc: coproc [%d:%s] still exists\n", old.cmd.Process.Pid, name)
+		}
+		closeShellFd(old.fds[0])
+		closeShellFd(old.fds[1])
+		delete(coprocesses, name)
+	}
+	cmd, err := subshellCommand(command)
+	if err != nil {
+		fmt.Fprintln(errWriter, "coproc: "+err.Error())
+		return 1
+	}
+	inputReader, inputWriter, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(errWriter, "coproc: "+err.Error())
+		return 1
+	}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		inputReader.Close()
+		inputWriter.Close()
+		fmt.Fprintln(errWriter, "coproc: "+err.Error())
+		return 1
+	}
+	cmd.Stdin = inputReader
+	cmd.Stdout = outputWriter
+	cmd.Stderr = errWriter
+	// as a job the coprocess gets a process group, which kill %N signals
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err = cmd.Start()
+	inputReader.Close()
+	outputWriter.Close()
+	if err != nil {
+		inputWriter.Close()
+		outputReader.Close()
+		return reportCommandError(errWriter, "coproc", nil, err)
+	}
+	// reap the coprocess whenever it exits, its pipes stay open until the
+	// shell closes them so no output is lost
+	proc := &coprocess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		accountProcess(cmd.ProcessState)
+		close(proc.done)
+	}()
+	for i, f := range []*os.File{outputReader, inputWriter} {
+		proc.fds[i] = unusedCoprocFd()
+		coprocFds[proc.fds[i]] = f
+	}
+	coprocesses[name] = proc
+	addJob(cmd.Process.Pid, strings.TrimSpace(input), proc.done)
+	err = assignArray(name, []string{strconv.Itoa(proc.fds[0]), strconv.Itoa(proc.fds[1])})
+	if err == nil {
+		err = setVar(name+"_PID", strconv.Itoa(cmd.Process.Pid))
+	}
+	if err != nil {
+		fmt.Fprintln(errWriter, "coproc: "+err.Error())
+		return 1
+	}
+	return 0
+}
+
+// unusedCoprocFd picks the highest descriptor number below 64 the shell is
+// not using yet, as bash does
+func unusedCoprocFd() int {
+	fd := 63
+	for fd > 10 {
+		_, isShellFd := shellFds[fd]
+		_, isCoprocFd := coprocFds[fd]
+		if !isShellFd && !isCoprocFd {
+			break
+		}
+		fd--
+	}
+	return fd
+}
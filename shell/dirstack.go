@@ -0,0 +1,256 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dirStack holds the directories saved by pushd, most recent first. The top
+// of the stack as shown by dirs is always the current directory, so it is
+// not stored here
+var dirStack []string = []string{}
+
+func currentDirectory() string {
+	if pwd := logicalWorkingDir(); pwd != "" {
+		return pwd
+	}
+	workingDir, _ := os.Getwd()
+	return workingDir
+}
+
+// directoryStack returns the whole stack as dirs displays it, starting with
+// the current directory
+func directoryStack() []string {
+	return append([]string{currentDirectory()}, dirStack...)
+}
+
+// parseStackIndex converts a +N or -N argument into an index of the whole
+// stack. +N counts from the left of the list printed by dirs (starting at
+// zero) and -N from the right
+func parseStackIndex(spec string) (int, bool) {
+	fromRight := strings.HasPrefix(spec, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(spec, "+"), "-")
+	if digits == "" || digits[0] < '0' || digits[0] > '9' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	size := len(dirStack) + 1
+	if fromRight {
+		n = size - 1 - n
+	}
+	if n < 0 || n >= size {
+		return 0, false
+	}
+	return n, true
+}
+
+// directoryStackEntry resolves the N, +N or -N of a ~N tilde prefix
+func directoryStackEntry(spec string) (string, bool) {
+	idx, ok := parseStackIndex(spec)
+	if !ok {
+		return "", false
+	}
+	return directoryStack()[idx], true
+}
+
+func isStackIndexArg(arg string) bool {
+	if len(arg) < 2 || (arg[0] != '+' && arg[0] != '-') {
+		return false
+	}
+	_, err := strconv.Atoi(arg[1:])
+	return err == nil
+}
+
+// abbreviateHome replaces the home directory at the start of dir with ~
+func abbreviateHome(dir string) string {
+	homeDir := lookupVar("HOME")
+	if homeDir == "" || homeDir == "/" {
+		return dir
+	}
+	if dir == homeDir {
+		return "~"
+	}
+	if strings.HasPrefix(dir, homeDir+"/") {
+		return "~" + dir[len(homeDir):]
+	}
+	return dir
+}
+
+func printDirectoryStack(outputWriter io.Writer, longForm, onePerLine, numbered bool) {
+	entries := directoryStack()
+	if !longForm {
+		for i := range entries {
+			entries[i] = abbreviateHome(entries[i])
+		}
+	}
+	switch {
+	case numbered:
+		for i, entry := range entries {
+			fmt.Fprintf(outputWriter, "%2d  %s\n", i, entry)
+		}
+	case onePerLine:
+		for _, entry := range entries {
+			fmt.Fprintln(outputWriter, entry)
+		}
+	default:
+		fmt.Fprintln(outputWriter, strings.Join(entries, " "))
+	}
+}
+
+func dirsBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	longForm := false
+	onePerLine := false
+	numbered := false
+	clearStack := false
+	for _, arg := range argsParts {
+		if isStackIndexArg(arg) {
+			idx, ok := parseStackIndex(arg)
+			if !ok {
+				fmt.Fprintln(errWriter, "dirs: "+arg[1:]+": directory stack index out of range")
+				return 1
+			}
+			entry := directoryStack()[idx]
+			if !longForm {
+				entry = abbreviateHome(entry)
+			}
+			fmt.Fprintln(outputWriter, entry)
+			return 0
+		}
+		if !strings.HasPrefix(arg, "-") || len(arg) < 2 {
+			fmt.Fprintln(errWriter, "dirs: "+arg+": invalid argument")
+			fmt.Fprintln(errWriter, "dirs: usage: dirs [-clpv] [+N] [-N]")
+			return 1
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'c':
+				clearStack = true
+			case 'l':
+				longForm = true
+			case 'p':
+				onePerLine = true
+			case 'v':
+				numbered = true
+			default:
+				fmt.Fprintln(errWriter, "dirs: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "dirs: usage: dirs [-clpv] [+N] [-N]")
+				return 2
+			}
+		}
+	}
+	if clearStack {
+		dirStack = dirStack[:0]
+		return 0
+	}
+	printDirectoryStack(outputWriter, longForm, onePerLine, numbered)
+	return 0
+}
+
+func pushdBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	noChange := false
+	args := argsParts
+	if len(args) > 0 && args[0] == "-n" {
+		noChange = true
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errWriter, "pushd: too many arguments")
+		return 1
+	}
+	oldDir := currentDirectory()
+	switch {
+	case len(args) == 0:
+		// exchange the top two directories
+		if len(dirStack) == 0 {
+			fmt.Fprintln(errWriter, "pushd: no other directory")
+			return 1
+		}
+		if !noChange {
+			if err := changeDirectory(dirStack[0], false); err != nil {
+				fmt.Fprintln(errWriter, "pushd: "+dirStack[0]+": "+describeChdirError(err))
+				return 1
+			}
+			dirStack[0] = oldDir
+		}
+	case isStackIndexArg(args[0]):
+		// rotate the stack so that the given entry is on top
+		idx, ok := parseStackIndex(args[0])
+		if !ok {
+			fmt.Fprintln(errWriter, "pushd: "+args[0]+": directory stack index out of range")
+			return 1
+		}
+		entries := directoryStack()
+		rotated := append(entries[idx:], entries[:idx]...)
+		if !noChange {
+			if err := changeDirectory(rotated[0], false); err != nil {
+				fmt.Fprintln(errWriter, "pushd: "+rotated[0]+": "+describeChdirError(err))
+				return 1
+			}
+		}
+		dirStack = rotated[1:]
+	default:
+		dir, _ := searchCdPath(expandTilde(args[0]))
+		if noChange {
+			dirStack = append([]string{dir}, dirStack...)
+			break
+		}
+		if err := changeDirectory(dir, false); err != nil {
+			fmt.Fprintln(errWriter, "pushd: "+args[0]+": "+describeChdirError(err))
+			return 1
+		}
+		dirStack = append([]string{oldDir}, dirStack...)
+	}
+	printDirectoryStack(outputWriter, false, false, false)
+	return 0
+}
+
+func popdBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	noChange := false
+	args := argsParts
+	if len(args) > 0 && args[0] == "-n" {
+		noChange = true
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errWriter, "popd: too many arguments")
+		return 1
+	}
+	if len(dirStack) == 0 {
+		fmt.Fprintln(errWriter, "popd: directory stack empty")
+		return 1
+	}
+	idx := 0
+	if len(args) == 1 {
+		if !isStackIndexArg(args[0]) {
+			fmt.Fprintln(errWriter, "popd: "+args[0]+": invalid argument")
+			fmt.Fprintln(errWriter, "popd: usage: popd [-n] [+N | -N]")
+			return 1
+		}
+		var ok bool
+		if idx, ok = parseStackIndex(args[0]); !ok {
+			fmt.Fprintln(errWriter, "popd: "+args[0]+": directory stack index out of range")
+			return 1
+		}
+	}
+	if idx == 0 && noChange {
+		// -n leaves the current directory alone and drops the entry below it
+		idx = 1
+	}
+	if idx == 0 {
+		if err := changeDirectory(dirStack[0], false); err != nil {
+			fmt.Fprintln(errWriter, "popd: "+dirStack[0]+": "+describeChdirError(err))
+			return 1
+		}
+		dirStack = dirStack[1:]
+	} else {
+		dirStack = append(dirStack[:idx-1], dirStack[idx:]...)
+	}
+	printDirectoryStack(outputWriter, false, false, false)
+	return 0
+}
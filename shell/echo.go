@@ -0,0 +1,43 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// echoBuiltin prints its arguments separated by spaces. Leading words made
+// only of the letters n, e and E are options: -n drops the trailing newline,
+// -e interprets backslash escapes and -E turns that off again. Escapes are
+// interpreted by default when the xpg_echo shell option is set
+func echoBuiltin(argsParts []string, outputWriter io.Writer) int {
+	interpret := shellOptions["xpg_echo"]
+	newline := true
+	args := argsParts
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				interpret = true
+			case 'E':
+				interpret = false
+			}
+		}
+		args = args[1:]
+	}
+	output := strings.Join(args, " ")
+	if interpret {
+		var stop bool
+		if output, stop = interpretEscapes(output, true, true); stop {
+			// \c suppresses everything that follows, newline included
+			newline = false
+		}
+	}
+	if newline {
+		output += "\n"
+	}
+	fmt.Fprint(outputWriter, output)
+	return 0
+}
@@ -0,0 +1,110 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// evalBuiltin joins its arguments into one line and runs that as a command
+// list of its own, with the streams eval itself was given
+func evalBuiltin(argsParts []string, streams Streams) int {
+	line := strings.TrimSpace(strings.Join(argsParts, " "))
+	if line == "" {
+		return 0
+	}
+	runCommandList(line, lookupVar("PATH"), streams)
+	return lastExitStatus
+}
+
+// isExecCommand reports whether input runs the exec builtin, whose
+// redirections apply to the shell itself rather than to a single command
+func isExecCommand(input string) bool {
+	words := tokenizeWords(input, true)
+	return len(words) > 0 && words[0].text == "exec" && !slices.Contains(words[0].quoted, true)
+}
+
+// execCommand runs an exec command line. Its redirections change the
+// shell's own file descriptors for good and a command, if there is one,
+// then replaces the shell
+func execCommand(input string, errWriter io.Writer) int {
+	words, err := applyShellRedirections(tokenizeWords(input, true)[1:])
+	if err != nil {
+		fmt.Fprintln(errWriter, "exec: "+err.Error())
+		return 1
+	}
+	args := make([]string, len(words))
+	for i, word := range words {
+		args[i] = word.text
+	}
+	return execBuiltin(args, errWriter)
+}
+
+// execBuiltin replaces the shell with the given command, saving the history
+// first as exit would. Without a command there is nothing to do
+func execBuiltin(argsParts []string, errWriter io.Writer) int {
+	args := argsParts
+	argv0 := ""
+	clearEnv := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		flags := args[0][1:]
+		args = args[1:]
+		for j, flag := range flags {
+			switch flag {
+			case 'c':
+				clearEnv = true
+			case 'a':
+				value := flags[j+1:]
+				if value == "" {
+					if len(args) == 0 {
+						fmt.Fprintln(errWriter, "exec: -a: option requires an argument")
+						return 2
+					}
+					value = args[0]
+					args = args[1:]
+				}
+				argv0 = value
+			default:
+				fmt.Fprintln(errWriter, "exec: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "exec: usage: exec [-c] [-a name] [command [argument ...]]")
+				return 2
+			}
+			if flag == 'a' {
+				break
+			}
+		}
+	}
+	if len(args) == 0 {
+		return 0
+	}
+	pathToExecutable, err := resolveCommand(args[0], strings.Split(lookupVar("PATH"), ":"))
+	if err != nil {
+		if err == errCommandNotFound {
+			fmt.Fprintln(errWriter, "exec: "+args[0]+": not found")
+			return 127
+		}
+		return reportCommandError(errWriter, args[0], args[1:], err)
+	}
+	if argv0 != "" {
+		args[0] = argv0
+	}
+	env := os.Environ()
+	if clearEnv {
+		env = []string{}
+	}
+	saveHistory()
+	// the new program gets the descriptors opened by exec at their numbers
+	for fd, f := range shellFds {
+		dupFd(int(f.Fd()), fd)
+	}
+	err = syscall.Exec(pathToExecutable, args, env)
+	fmt.Fprintln(errWriter, "exec: "+args[0]+": "+describeChdirError(err))
+	return 126
+}
@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// isGroupCommand reports whether input is a ( ) subshell or a { } group
+func isGroupCommand(input string) bool {
+	input = strings.TrimSpace(input)
+	return strings.HasPrefix(input, "(") || (strings.HasPrefix(input, "{") && (len(input) == 1 || strings.IndexByte(" \t\n", input[1]) != -1))
+}
+
+// groupCommand runs a group with the redirections that follow it applied to
+// the group as a whole. A { } group runs in the shell itself, while a ( )
+// subshell runs in a copy of the shell so nothing it changes outlives it
+func groupCommand(input, PATH string, streams Streams) int {
+	input = strings.TrimSpace(input)
+	var scanner lineScanner
+	end := -1
+	for i := range input {
+		scanner.step(input, i)
+		if scanner.groupDepth == 0 {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		fmt.Fprintln(streams.Stderr, "syntax error: unexpected end of file")
+		return 2
+	}
+	body := strings.TrimSpace(input[1:end])
+	if body == "" {
+		fmt.Fprintln(streams.Stderr, "syntax error near unexpected token `"+input[end:end+1]+"'")
+		return 2
+	}
+	rest := input[end+1:]
+	if leftover := strings.Fields(removeRedirection(rest)); len(leftover) > 0 {
+		fmt.Fprintln(streams.Stderr, "syntax error near unexpected token `"+leftover[0]+"'")
+		return 2
+	}
+	outputPath, errPath, outputAppendPath, errAppendPath := parseOutputRedirect(rest)
+	outputWriter, errWriter, openedFiles, err := openOutputRedirects(streams, outputPath, errPath, outputAppendPath, errAppendPath)
+	if err != nil {
+		fmt.Fprintln(streams.Stderr, "Error creating out/err writer: "+err.Error())
+		return 1
+	}
+	for _, f := range openedFiles {
+		defer f.Close()
+	}
+	groupStreams := Streams{Stdin: streams.Stdin, Stdout: outputWriter, Stderr: errWriter}
+	if input[0] == '{' {
+		runCommandList(body, PATH, groupStreams)
+		return lastExitStatus
+	}
+	return runSubshell(body, groupStreams)
+}
+
+// runSubshell runs body in a subshell and waits for it to finish
+func runSubshell(body string, streams Streams) int {
+	cmd, err := subshellCommand(body)
+	if err != nil {
+		fmt.Fprintln(streams.Stderr, "subshell: "+err.Error())
+		return 1
+	}
+	cmd.Stdin = streams.Stdin
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
+	err = cmd.Run()
+	accountProcess(cmd.ProcessState)
+	return exitStatus(err)
+}
+
+// subshellCommand prepares `goshell -c` to run body, giving the new shell
+// the variables, aliases and settings of this one first. Exported variables
+// and the working directory are inherited by the process anyway
+func subshellCommand(body string) (*exec.Cmd, error) {
+	executable := subshellExecutable
+	if executable == "" {
+		var err error
+		if executable, err = os.Executable(); err != nil {
+			return nil, err
+		}
+	}
+	cmd := newCommand(executable, "-c", subshellScript(body))
+	cmd.ExtraFiles = extraFiles()
+	return cmd, nil
+}
+
+// subshellScript returns body preceded by the commands that recreate the
+// state of this shell
+func subshellScript(body string) string {
+	prelude := make([]string, 0)
+	for _, name := range declaredNames() {
+		flags := declarationFlags(name)
+		if flags == "-x" {
+			continue
+		}
+		if declaration, ok := formatDeclaration(name); ok {
+			prelude = append(prelude, "declare "+flags+" "+declaration)
+		}
+	}
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prelude = append(prelude, "alias "+name+"="+quoteAliasValue(aliases[name]))
+	}
+	for name, enabled := range shellOptions {
+		if enabled {
+			prelude = append(prelude, "shopt -s "+name)
+		}
+	}
+	for name := range disabledBuiltins {
+		prelude = append(prelude, "enable -n "+name)
+	}
+	return strings.Join(append(prelude, body), "; ")
+}
@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hashedCommand is an entry in the table of commands that have already been
+// found in PATH along with the number of times it was used
+type hashedCommand struct {
+	path string
+	hits int
+}
+
+// commandHash remembers where commands were found so that running one does
+// not mean reading every PATH directory again. The table is only valid for
+// the PATH it was filled from (hashedPATH) and is emptied when PATH changes
+var commandHash map[string]*hashedCommand = map[string]*hashedCommand{}
+var hashedPATH string
+
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode()&0111 != 0
+}
+
+// findInPath searches the directories in order for an executable file named
+// commandName. Only the first match is returned unless all is set
+func findInPath(commandName string, directories []string, all bool) []string {
+	res := make([]string, 0)
+	for _, dir := range directories {
+		if dir == "" {
+			// an empty PATH entry means the current directory
+			dir = "."
+		}
+		candidate := strings.TrimSuffix(dir, "/") + "/" + commandName
+		if isExecutableFile(candidate) {
+			res = append(res, candidate)
+			if !all {
+				break
+			}
+		}
+	}
+	return res
+}
+
+// syncCommandHash throws away the cached locations if they were found using
+// a different PATH than the one about to be searched
+func syncCommandHash(directories []string) {
+	currentPATH := strings.Join(directories, ":")
+	if currentPATH != hashedPATH {
+		clear(commandHash)
+		hashedPATH = currentPATH
+	}
+}
+
+// lookupCommand returns the location of commandName, consulting the hash
+// table first and searching PATH (and remembering the result) on a miss.
+// A cached path that is no longer executable is dropped and searched again
+func lookupCommand(commandName string, directories []string) (string, bool) {
+	syncCommandHash(directories)
+	if entry, ok := commandHash[commandName]; ok {
+		if isExecutableFile(entry.path) {
+			entry.hits++
+			return entry.path, true
+		}
+		delete(commandHash, commandName)
+	}
+	matches := findInPath(commandName, directories, false)
+	if len(matches) == 0 {
+		return "", false
+	}
+	commandHash[commandName] = &hashedCommand{path: matches[0], hits: 1}
+	return matches[0], true
+}
+
+func hashBuiltin(argsParts []string, outputWriter, errWriter io.Writer, directories []string) int {
+	syncCommandHash(directories)
+	if len(argsParts) == 0 {
+		if len(commandHash) == 0 {
+			fmt.Fprintln(errWriter, "hash: hash table empty")
+			return 0
+		}
+		fmt.Fprintln(outputWriter, "hits\tcommand")
+		for _, name := range sortedHashNames() {
+			fmt.Fprintf(outputWriter, "%4d\t%s\n", commandHash[name].hits, commandHash[name].path)
+		}
+		return 0
+	}
+	status := 0
+	switch argsParts[0] {
+	case "-r":
+		clear(commandHash)
+		return 0
+	case "-l":
+		for _, name := range sortedHashNames() {
+			fmt.Fprintf(outputWriter, "builtin hash -p %s %s\n", commandHash[name].path, name)
+		}
+		return 0
+	case "-p":
+		if len(argsParts) < 3 {
+			fmt.Fprintln(errWriter, "hash: usage: hash -p path name")
+			return 2
+		}
+		for _, name := range argsParts[2:] {
+			commandHash[name] = &hashedCommand{path: argsParts[1]}
+		}
+		return 0
+	case "-d":
+		for _, name := range argsParts[1:] {
+			if _, ok := commandHash[name]; !ok {
+				fmt.Fprintln(errWriter, "hash: "+name+": not found")
+				status = 1
+				continue
+			}
+			delete(commandHash, name)
+		}
+		return status
+	case "-t":
+		names := argsParts[1:]
+		for _, name := range names {
+			entry, ok := commandHash[name]
+			if !ok {
+				fmt.Fprintln(errWriter, "hash: "+name+": not found")
+				status = 1
+				continue
+			}
+			if len(names) > 1 {
+				fmt.Fprintf(outputWriter, "%s\t%s\n", name, entry.path)
+			} else {
+				fmt.Fprintln(outputWriter, entry.path)
+			}
+		}
+		return status
+	}
+	if strings.HasPrefix(argsParts[0], "-") {
+		fmt.Fprintln(errWriter, "hash: "+argsParts[0]+": invalid option")
+		fmt.Fprintln(errWriter, "hash: usage: hash [-lr] [-p pathname] [-dt] [name ...]")
+		return 2
+	}
+	for _, name := range argsParts {
+		if strings.Contains(name, "/") {
+			continue
+		}
+		matches := findInPath(name, directories, false)
+		if len(matches) == 0 {
+			fmt.Fprintln(errWriter, "hash: "+name+": not found")
+			status = 1
+			continue
+		}
+		commandHash[name] = &hashedCommand{path: matches[0]}
+	}
+	return status
+}
+
+func sortedHashNames() []string {
+	names := make([]string, 0, len(commandHash))
+	for name := range commandHash {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// job is a command the shell left running in the background, in a process
+// group of its own so that signals reach everything it started
+type job struct {
+	id      int
+	pgid    int
+	command string
+	done    chan struct{}
+}
+
+// jobTable holds the background jobs in the order they were started. So far
+// these are the coprocesses
+var jobTable []*job
+
+var errNoSuchJob = errors.New("no such job")
+
+// finished reports whether the job's process has exited
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// pruneJobs forgets the jobs that have finished
+func pruneJobs() {
+	running := jobTable[:0]
+	for _, j := range jobTable {
+		if !j.finished() {
+			running = append(running, j)
+		}
+	}
+	clear(jobTable[len(running):])
+	jobTable = running
+}
+
+// addJob records a job whose process group leader is pgid. It is numbered
+// one past the highest job still running, as in bash
+func addJob(pgid int, command string, done chan struct{}) *job {
+	pruneJobs()
+	id := 1
+	if len(jobTable) > 0 {
+		id = jobTable[len(jobTable)-1].id + 1
+	}
+	j := &job{id: id, pgid: pgid, command: command, done: done}
+	jobTable = append(jobTable, j)
+	return j
+}
+
+// findJob returns the job a job spec refers to: %N by number, %+ or %% the
+// current (most recent) job, %- the one before it, %?text a job whose
+// command contains text and %text one whose command starts with it
+func findJob(spec string) (*job, error) {
+	pruneJobs()
+	ref := strings.TrimPrefix(spec, "%")
+	switch {
+	case ref == "" || ref == "%" || ref == "+":
+		if len(jobTable) > 0 {
+			return jobTable[len(jobTable)-1], nil
+		}
+	case ref == "-":
+		if len(jobTable) > 1 {
+			return jobTable[len(jobTable)-2], nil
+		}
+		if len(jobTable) == 1 {
+			return jobTable[0], nil
+		}
+	default:
+		if n, err := strconv.Atoi(ref); err == nil {
+			for _, j := range jobTable {
+				if j.id == n {
+					return j, nil
+				}
+			}
+			break
+		}
+		var match *job
+		for _, j := range jobTable {
+			var matches bool
+			if text, ok := strings.CutPrefix(ref, "?"); ok {
+				matches = strings.Contains(j.command, text)
+			} else {
+				matches = strings.HasPrefix(j.command, ref)
+			}
+			if !matches {
+				continue
+			}
+			if match != nil {
+				return nil, errors.New("ambiguous job spec")
+			}
+			match = j
+		}
+		if match != nil {
+			return match, nil
+		}
+	}
+	return nil, errNoSuchJob
+}
@@ -0,0 +1,325 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// rlimitNproc is RLIMIT_NPROC, which the syscall package doesn't name, as
+// numbered on Linux
+const rlimitNproc int = 0x6
+
+// rlimInfinity is RLIM_INFINITY as the unsigned value an Rlimit holds
+const rlimInfinity uint64 = ^uint64(0)
+
+// resourceLimit is a limit ulimit can show or change. Values are given in
+// units of factor bytes (or of the resource itself when factor is 1)
+type resourceLimit struct {
+	option      byte
+	resource    int
+	factor      uint64
+	description string
+	unit        string
+}
+
+// resourceLimits are in the order ulimit -a lists them
+var resourceLimits []resourceLimit = []resourceLimit{
+	{'c', syscall.RLIMIT_CORE, 1024, "core file size", "blocks"},
+	{'d', syscall.RLIMIT_DATA, 1024, "data seg size", "kbytes"},
+	{'f', syscall.RLIMIT_FSIZE, 1024, "file size", "blocks"},
+	{'n', syscall.RLIMIT_NOFILE, 1, "open files", ""},
+	{'s', syscall.RLIMIT_STACK, 1024, "stack size", "kbytes"},
+	{'t', syscall.RLIMIT_CPU, 1, "cpu time", "seconds"},
+	{'u', rlimitNproc, 1, "max user processes", ""},
+	{'v', syscall.RLIMIT_AS, 1024, "virtual memory", "kbytes"},
+}
+
+const ulimitUsage string = "ulimit: usage: ulimit [-HSa] [-c|-d|-f|-n|-s|-t|-u|-v] [limit]"
+
+// ulimitBuiltin shows or sets the limits on the resources the shell and the
+// programs it starts may use. The limits are the shell's own, so children
+// inherit them. Without -H or -S a new limit sets both the soft and the hard
+// limit, and the soft one is shown
+func ulimitBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	hard := false
+	soft := false
+	all := false
+	limits := make([]resourceLimit, 0)
+	args := argsParts
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'H':
+				hard = true
+			case 'S':
+				soft = true
+			case 'a':
+				all = true
+			default:
+				limit, ok := findResourceLimit(byte(flag))
+				if !ok {
+					fmt.Fprintln(errWriter, "ulimit: -"+string(flag)+": invalid option")
+					fmt.Fprintln(errWriter, ulimitUsage)
+					return 2
+				}
+				limits = append(limits, limit)
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errWriter, "ulimit: too many arguments")
+		return 2
+	}
+	if all {
+		for _, limit := range resourceLimits {
+			printResourceLimit(outputWriter, limit, hard && !soft, true)
+		}
+		return 0
+	}
+	if len(limits) == 0 {
+		// the file size is what a bare ulimit is about
+		limits = append(limits, resourceLimits[2])
+	}
+	if len(args) == 0 {
+		for _, limit := range limits {
+			printResourceLimit(outputWriter, limit, hard && !soft, len(limits) > 1)
+		}
+		return 0
+	}
+	if !hard && !soft {
+		hard, soft = true, true
+	}
+	status := 0
+	for _, limit := range limits {
+		var rlimit syscall.Rlimit
+		if err := syscall.Getrlimit(limit.resource, &rlimit); err != nil {
+			fmt.Fprintf(errWriter, "ulimit: %s: cannot get limit: %s\n", limit.description, err)
+			status = 1
+			continue
+		}
+		var value uint64
+		switch args[0] {
+		case "unlimited":
+			value = rlimInfinity
+		case "hard":
+			value = rlimit.Max
+		case "soft":
+			value = rlimit.Cur
+		default:
+			n, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				fmt.Fprintln(errWriter, "ulimit: "+args[0]+": invalid number")
+				return 1
+			}
+			value = n * limit.factor
+		}
+		if hard {
+			rlimit.Max = value
+		}
+		if soft {
+			rlimit.Cur = value
+		}
+		if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+			fmt.Fprintf(errWriter, "ulimit: %s: cannot modify limit: %s\n", limit.description, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func findResourceLimit(option byte) (resourceLimit, bool) {
+	for _, limit := range resourceLimits {
+		if limit.option == option {
+			return limit, true
+		}
+	}
+	return resourceLimit{}, false
+}
+
+// printResourceLimit writes the soft (or hard) value of limit, along with
+// what it is about when more than one limit is shown
+func printResourceLimit(outputWriter io.Writer, limit resourceLimit, hard bool, labelled bool) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(limit.resource, &rlimit); err != nil {
+		rlimit = syscall.Rlimit{Cur: rlimInfinity, Max: rlimInfinity}
+	}
+	current := rlimit.Cur
+	if hard {
+		current = rlimit.Max
+	}
+	value := "unlimited"
+	if current != rlimInfinity {
+		value = strconv.FormatUint(current/limit.factor, 10)
+	}
+	if !labelled {
+		fmt.Fprintln(outputWriter, value)
+		return
+	}
+	option := "(-" + string(limit.option) + ")"
+	if limit.unit != "" {
+		option = "(" + limit.unit + ", -" + string(limit.option) + ")"
+	}
+	fmt.Fprintf(outputWriter, "%-20s%20s %s\n", limit.description, option, value)
+}
+
+// currentUmask returns the shell's file creation mask, which can only be
+// read by setting it
+func currentUmask() int {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return mask
+}
+
+// umaskBuiltin shows or sets the file creation mask. Files the shell or its
+// children create, such as the targets of redirections, don't get the
+// permissions it masks. A mode is either octal or symbolic as for chmod,
+// symbolic modes naming the permissions that are allowed
+func umaskBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	symbolic := false
+	args := argsParts
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'S':
+				symbolic = true
+			default:
+				fmt.Fprintln(errWriter, "umask: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "umask: usage: umask [-S] [mode]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+	mask := currentUmask()
+	if len(args) == 0 {
+		if symbolic {
+			fmt.Fprintln(outputWriter, symbolicMode(^mask&0777))
+		} else {
+			fmt.Fprintf(outputWriter, "%04o\n", mask)
+		}
+		return 0
+	}
+	mode := args[0]
+	if mode[0] >= '0' && mode[0] <= '9' {
+		n, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || n > 0777 {
+			fmt.Fprintln(errWriter, "umask: "+mode+": octal number out of range")
+			return 1
+		}
+		mask = int(n)
+	} else {
+		allowed, ok := applySymbolicMode(^mask&0777, mode)
+		if !ok {
+			fmt.Fprintln(errWriter, "umask: `"+mode+"': invalid symbolic mode operator")
+			return 1
+		}
+		mask = ^allowed & 0777
+	}
+	syscall.Umask(mask)
+	if symbolic {
+		fmt.Fprintln(outputWriter, symbolicMode(^mask&0777))
+	}
+	return 0
+}
+
+// permissionClasses are the who letters of a symbolic mode with the shift
+// of their rwx bits
+var permissionClasses []struct {
+	who   byte
+	shift uint
+} = []struct {
+	who   byte
+	shift uint
+}{{'u', 6}, {'g', 3}, {'o', 0}}
+
+// symbolicMode writes permission bits as u=rwx,g=rx,o=rx
+func symbolicMode(perm int) string {
+	parts := make([]string, 0, len(permissionClasses))
+	for _, class := range permissionClasses {
+		bits := perm >> class.shift & 07
+		part := string(class.who) + "="
+		for i, letter := range "rwx" {
+			if bits&(04>>i) != 0 {
+				part += string(letter)
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// applySymbolicMode changes perm by the comma separated clauses of a chmod
+// style mode such as u=rwx,g-w,o=. Each clause names who it is for (all of
+// u, g and o when left out) and then one or more operations of +, - or =
+// with the letters r, w and x or a class to copy from
+func applySymbolicMode(perm int, mode string) (int, bool) {
+	for _, clause := range strings.Split(mode, ",") {
+		i := 0
+		who := 0
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) != -1; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0700
+			case 'g':
+				who |= 0070
+			case 'o':
+				who |= 0007
+			case 'a':
+				who |= 0777
+			}
+		}
+		if who == 0 {
+			who = 0777
+		}
+		if i == len(clause) {
+			return 0, false
+		}
+		for i < len(clause) {
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return 0, false
+			}
+			i++
+			bits := 0
+			for ; i < len(clause) && strings.IndexByte("rwxugo", clause[i]) != -1; i++ {
+				switch clause[i] {
+				case 'r':
+					bits |= 0444
+				case 'w':
+					bits |= 0222
+				case 'x':
+					bits |= 0111
+				default:
+					// a class stands for the bits it currently has
+					for _, class := range permissionClasses {
+						if class.who == clause[i] {
+							bits |= (perm >> class.shift & 07) * 0111
+						}
+					}
+				}
+			}
+			bits &= who
+			switch op {
+			case '+':
+				perm |= bits
+			case '-':
+				perm &^= bits
+			case '=':
+				perm = perm&^who | bits
+			}
+		}
+	}
+	return perm, true
+}
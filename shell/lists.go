@@ -0,0 +1,227 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineScanner follows the quoting state while walking a command line so
+// that operators are only recognised where the shell would act on them
+type lineScanner struct {
+	inSingleQuotes bool
+	inDoubleQuotes bool
+	// in $'...' a backslash escapes the closing quote too
+	inAnsiQuotes bool
+	afterDollar  bool
+	escaped      bool
+	// inside [[ ]] the characters & | < > are part of the expression
+	inConditional bool
+	// groupDepth counts the ( ) subshells and { } groups that are open,
+	// whose contents are only split up once the group runs
+	groupDepth int
+}
+
+// advance moves the scanner over input[i], following quotes and escapes
+// only, and reports whether that byte is unquoted
+func (ls *lineScanner) advance(input string, i int) bool {
+	char := input[i]
+	afterDollar := ls.afterDollar
+	ls.afterDollar = false
+	switch {
+	case ls.escaped:
+		ls.escaped = false
+		return false
+	case ls.inSingleQuotes:
+		ls.inSingleQuotes = char != '\''
+		return false
+	case char == '\\':
+		ls.escaped = true
+		return false
+	case ls.inAnsiQuotes:
+		ls.inAnsiQuotes = char != '\''
+		return false
+	case ls.inDoubleQuotes:
+		ls.inDoubleQuotes = char != '"'
+		return false
+	case char == '\'':
+		ls.inAnsiQuotes = afterDollar
+		ls.inSingleQuotes = !afterDollar
+		return false
+	case char == '"':
+		ls.inDoubleQuotes = true
+		return false
+	}
+	ls.afterDollar = char == '$'
+	return true
+}
+
+// step advances the scanner over input[i] and reports whether that byte is
+// unquoted and outside of any [[ ]] expression or group
+func (ls *lineScanner) step(input string, i int) bool {
+	if !ls.advance(input, i) {
+		return false
+	}
+	atWordStart := i == 0 || strings.IndexByte(" \t\n;&|(", input[i-1]) != -1
+	atWordEnd := i+2 >= len(input) || strings.IndexByte(" \t\n;&|)", input[i+2]) != -1
+	switch {
+	case !ls.inConditional && atWordStart && strings.HasPrefix(input[i:], "[[") && atWordEnd:
+		ls.inConditional = true
+	case ls.inConditional && input[i-1] == ' ' && strings.HasPrefix(input[i:], "]]") && atWordEnd:
+		ls.inConditional = false
+		return false
+	case ls.inConditional:
+		return false
+	case input[i] == '(' && (atWordStart || ls.groupDepth > 0 || strings.IndexByte("<>", input[i-1]) != -1):
+		ls.groupDepth++
+		return false
+	case input[i] == ')' && ls.groupDepth > 0:
+		ls.groupDepth--
+		return false
+	case input[i] == '{' && atWordStart && (i+1 == len(input) || strings.IndexByte(" \t\n", input[i+1]) != -1):
+		ls.groupDepth++
+		return false
+	case input[i] == '}' && ls.groupDepth > 0 && closesBraceGroup(input, i):
+		ls.groupDepth--
+		return false
+	}
+	return ls.groupDepth == 0
+}
+
+// closesBraceGroup reports whether the } at input[i] is a word of its own
+// in command position, where it ends a { } group
+func closesBraceGroup(input string, i int) bool {
+	if i+1 < len(input) && strings.IndexByte(" \t\n;&|)", input[i+1]) == -1 {
+		return false
+	}
+	before := strings.TrimRight(input[:i], " \t")
+	return before != "" && strings.IndexByte(";&}\n", before[len(before)-1]) != -1
+}
+
+// stripComments removes comments, which run from an unquoted # at the start
+// of a word to the end of the line, and backslash-newline pairs, which join
+// lines
+func stripComments(input string) string {
+	var res strings.Builder
+	var scanner lineScanner
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\\' && strings.HasPrefix(input[i+1:], "\n") && !scanner.escaped && !scanner.inSingleQuotes && !scanner.inAnsiQuotes {
+			i++
+			continue
+		}
+		if !scanner.advance(input, i) || input[i] != '#' || i > 0 && strings.IndexByte(" \t\n;&|()", input[i-1]) == -1 {
+			res.WriteByte(input[i])
+			continue
+		}
+		end := strings.IndexByte(input[i:], '\n')
+		if end == -1 {
+			break
+		}
+		i += end - 1
+	}
+	return res.String()
+}
+
+// incompleteLine reports whether input ends inside quotes or a group, or
+// with a backslash, so that the command goes on on the next line
+func incompleteLine(input string) bool {
+	input = stripComments(input)
+	var scanner lineScanner
+	for i := range input {
+		scanner.step(input, i)
+	}
+	return scanner.inSingleQuotes || scanner.inDoubleQuotes || scanner.inAnsiQuotes || scanner.escaped || scanner.groupDepth > 0
+}
+
+// listItem is one pipeline of a command list along with the operator
+// (";", "&&", "||" or "" for the last one) that follows it
+type listItem struct {
+	command  string
+	operator string
+}
+
+func separateCommandList(input string) []listItem {
+	items := make([]listItem, 0)
+	var scanner lineScanner
+	start := 0
+	for i := 0; i < len(input); i++ {
+		if !scanner.step(input, i) {
+			continue
+		}
+		operator := ""
+		switch {
+		case strings.HasPrefix(input[i:], "&&"):
+			operator = "&&"
+		case strings.HasPrefix(input[i:], "||"):
+			operator = "||"
+		case input[i] == ';':
+			operator = ";"
+		case input[i] == '\n' && strings.TrimSpace(input[start:i]) != "":
+			// a newline ends a command, but not one that is yet to start
+			// as after && or a blank line
+			operator = ";"
+		}
+		if operator == "" {
+			continue
+		}
+		items = append(items, listItem{command: strings.TrimSpace(input[start:i]), operator: operator})
+		i += len(operator) - 1
+		start = i + 1
+	}
+	if rest := strings.TrimSpace(input[start:]); rest != "" {
+		items = append(items, listItem{command: rest})
+	}
+	return items
+}
+
+// runCommandList runs each pipeline of a command list in turn. A pipeline
+// after && only runs if the previous one succeeded and one after || only if
+// it failed, otherwise it is skipped and the exit status carries over
+func runCommandList(input, PATH string, streams Streams) {
+	run := true
+	for _, item := range separateCommandList(stripComments(input)) {
+		if shellExited || shellContext.Err() != nil {
+			return
+		}
+		if run && item.command != "" {
+			runPipeline(item.command, PATH, streams)
+		}
+		switch item.operator {
+		case "&&":
+			run = lastExitStatus == 0
+		case "||":
+			run = lastExitStatus != 0
+		default:
+			run = true
+		}
+	}
+}
+
+// runPipeline runs a pipeline within the limit CMDTMOUT sets, if any
+func runPipeline(command, PATH string, streams Streams) {
+	if isTimeCommand(command) {
+		// time reports on the whole pipeline, however long it is allowed to run
+		timePipeline(command, PATH, streams)
+		return
+	}
+	lastExitStatus = runWithTimeout(commandTimeout(), killGracePeriod, stopSignal, func() int {
+		runPipelineStages(command, PATH, streams)
+		return lastExitStatus
+	})
+}
+
+func runPipelineStages(command, PATH string, streams Streams) {
+	expandedCommand, substitutions, err := startProcessSubstitutions(expandAlias(command), streams)
+	if err != nil {
+		fmt.Fprintln(streams.Stderr, err.Error())
+		lastExitStatus = 1
+		pipeStatus = []int{lastExitStatus}
+		return
+	}
+	defer reapProcessSubstitutions(substitutions)
+	if pipedCommands := separatePipedCommands(expandedCommand); len(pipedCommands) > 1 {
+		pipedCommandProccesor(pipedCommands, PATH, streams)
+	} else {
+		commandProcessor(expandedCommand, PATH, streams)
+		pipeStatus = []int{lastExitStatus}
+	}
+}
@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// shellOptions are the optional shell behaviours toggled with shopt
+var shellOptions map[string]bool = map[string]bool{
+	"xpg_echo": false,
+}
+
+func shoptBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	set := false
+	unset := false
+	quiet := false
+	reusable := false
+	names := argsParts
+	for len(names) > 0 && len(names[0]) > 1 && names[0][0] == '-' {
+		for _, flag := range names[0][1:] {
+			switch flag {
+			case 's':
+				set = true
+			case 'u':
+				unset = true
+			case 'q':
+				quiet = true
+			case 'p':
+				reusable = true
+			default:
+				fmt.Fprintln(errWriter, "shopt: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "shopt: usage: shopt [-pqsu] [optname ...]")
+				return 2
+			}
+		}
+		names = names[1:]
+	}
+	if set && unset {
+		fmt.Fprintln(errWriter, "shopt: cannot set and unset shell options simultaneously")
+		return 1
+	}
+	if len(names) == 0 {
+		for name := range shellOptions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if set || unset {
+			// only list the options that are in the requested state
+			filtered := names[:0]
+			for _, name := range names {
+				if shellOptions[name] == set {
+					filtered = append(filtered, name)
+				}
+			}
+			names = filtered
+			set, unset = false, false
+		}
+	}
+	status := 0
+	for _, name := range names {
+		enabled, ok := shellOptions[name]
+		if !ok {
+			fmt.Fprintln(errWriter, "shopt: "+name+": invalid shell option name")
+			status = 1
+			continue
+		}
+		switch {
+		case set:
+			shellOptions[name] = true
+		case unset:
+			shellOptions[name] = false
+		case quiet:
+			if !enabled {
+				status = 1
+			}
+		case reusable:
+			flag := "-u"
+			if enabled {
+				flag = "-s"
+			}
+			fmt.Fprintln(outputWriter, "shopt "+flag+" "+name)
+		default:
+			state := "off"
+			if enabled {
+				state = "on"
+			}
+			fmt.Fprintf(outputWriter, "%-15s\t%s\n", name, state)
+			if !enabled {
+				status = 1
+			}
+		}
+	}
+	return status
+}
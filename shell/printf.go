@@ -0,0 +1,439 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInvalidNumber = errors.New("invalid number")
+
+// interpretEscapes expands the backslash escapes understood by printf and
+// echo -e. Octal escapes are \NNN in a printf format but need a leading
+// zero (\0NNN) in %b arguments and echo. When allowStop is set \c ends the
+// output, which is reported through the returned bool
+func interpretEscapes(input string, zeroOctal, allowStop bool) (string, bool) {
+	var res strings.Builder
+	for i := 0; i < len(input); i++ {
+		if input[i] != '\\' {
+			res.WriteByte(input[i])
+			continue
+		}
+		var expanded string
+		var stop bool
+		expanded, i, stop = expandEscape(input, i, zeroOctal, allowStop)
+		res.WriteString(expanded)
+		if stop {
+			return res.String(), true
+		}
+	}
+	return res.String(), false
+}
+
+// ansiCString decodes the $'...' string starting at input[start], whose
+// escapes are those of a printf format. It returns the text and the index
+// of the closing quote
+func ansiCString(input string, start int) (string, int) {
+	var res strings.Builder
+	for i := start + 2; i < len(input); i++ {
+		switch input[i] {
+		case '\'':
+			return res.String(), i
+		case '\\':
+			var expanded string
+			expanded, i, _ = expandEscape(input, i, false, false)
+			res.WriteString(expanded)
+		default:
+			res.WriteByte(input[i])
+		}
+	}
+	return res.String(), len(input) - 1
+}
+
+// expandEscape expands the single escape sequence starting at the backslash
+// input[i]. It returns the expansion, the index of the last byte consumed
+// and whether the escape was a \c asking for output to stop
+func expandEscape(input string, i int, zeroOctal, allowStop bool) (string, int, bool) {
+	if i == len(input)-1 {
+		return "\\", i, false
+	}
+	i++
+	switch input[i] {
+	case 'a':
+		return "\a", i, false
+	case 'b':
+		return "\b", i, false
+	case 'e', 'E':
+		return "\x1b", i, false
+	case 'f':
+		return "\f", i, false
+	case 'n':
+		return "\n", i, false
+	case 'r':
+		return "\r", i, false
+	case 't':
+		return "\t", i, false
+	case 'v':
+		return "\v", i, false
+	case '\\':
+		return "\\", i, false
+	case 'c':
+		if allowStop {
+			return "", i, true
+		}
+	case 'x', 'u', 'U':
+		maxDigits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[input[i]]
+		end := i + 1
+		for end < len(input) && end-i-1 < maxDigits && strings.IndexByte("0123456789abcdefABCDEF", input[end]) != -1 {
+			end++
+		}
+		if end == i+1 {
+			break
+		}
+		value, _ := strconv.ParseUint(input[i+1:end], 16, 32)
+		if input[i] == 'x' {
+			return string([]byte{byte(value)}), end - 1, false
+		}
+		return string(rune(value)), end - 1, false
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		start := i
+		if zeroOctal {
+			if input[i] != '0' {
+				break
+			}
+			start = i + 1
+		}
+		end := start
+		for end < len(input) && end-start < 3 && input[end] >= '0' && input[end] <= '7' {
+			end++
+		}
+		value, _ := strconv.ParseUint("0"+input[start:end], 8, 16)
+		return string([]byte{byte(value)}), end - 1, false
+	case '"', '\'', '?':
+		if !zeroOctal {
+			return string(input[i]), i, false
+		}
+	}
+	return "\\" + string(input[i]), i, false
+}
+
+// parseIntArg converts a numeric printf argument the way bash does: decimal,
+// 0x hex and leading 0 octal are accepted, and 'c or "c gives the character
+// code of c. When only a prefix is numeric its value is returned together
+// with errInvalidNumber
+func parseIntArg(arg string) (int64, error) {
+	trimmed := strings.TrimLeft(arg, " \t\n")
+	if trimmed == "" {
+		return 0, nil
+	}
+	if trimmed[0] == '\'' || trimmed[0] == '"' {
+		if len(trimmed) == 1 {
+			return 0, nil
+		}
+		r, _ := utf8.DecodeRuneInString(trimmed[1:])
+		return int64(r), nil
+	}
+	sign := int64(1)
+	digits := trimmed
+	if digits[0] == '+' || digits[0] == '-' {
+		if digits[0] == '-' {
+			sign = -1
+		}
+		digits = digits[1:]
+	}
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		base = 16
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0") && len(digits) > 1:
+		base = 8
+		digits = digits[1:]
+	}
+	end := 0
+	for end < len(digits) {
+		digit := strings.IndexByte("0123456789abcdef", digits[end]|0x20)
+		if digit == -1 || digit >= base {
+			break
+		}
+		end++
+	}
+	var value uint64
+	if end > 0 {
+		value, _ = strconv.ParseUint(digits[:end], base, 64)
+	}
+	if end != len(digits) || (end == 0 && base != 8) {
+		return sign * int64(value), errInvalidNumber
+	}
+	return sign * int64(value), nil
+}
+
+func parseFloatArg(arg string) (float64, error) {
+	trimmed := strings.TrimSpace(arg)
+	if trimmed == "" {
+		return 0, nil
+	}
+	if trimmed[0] == '\'' || trimmed[0] == '"' {
+		value, err := parseIntArg(trimmed)
+		return float64(value), err
+	}
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil {
+		intValue, intErr := parseIntArg(trimmed)
+		if intErr == nil {
+			return float64(intValue), nil
+		}
+		return float64(intValue), errInvalidNumber
+	}
+	return value, nil
+}
+
+// shellQuote quotes value so the shell would read it back as one word, as
+// done by printf %q
+func shellQuote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	printable := true
+	for _, char := range value {
+		if char < ' ' || char == 0x7f {
+			printable = false
+		}
+	}
+	if !printable {
+		var res strings.Builder
+		res.WriteString("$'")
+		for _, char := range value {
+			switch char {
+			case '\n':
+				res.WriteString(`\n`)
+			case '\t':
+				res.WriteString(`\t`)
+			case '\r':
+				res.WriteString(`\r`)
+			case 0x1b:
+				res.WriteString(`\E`)
+			case '\'', '\\':
+				res.WriteByte('\\')
+				res.WriteRune(char)
+			default:
+				if char < ' ' || char == 0x7f {
+					fmt.Fprintf(&res, "\\%03o", char)
+				} else {
+					res.WriteRune(char)
+				}
+			}
+		}
+		res.WriteByte('\'')
+		return res.String()
+	}
+	var res strings.Builder
+	for i, char := range value {
+		if strings.ContainsRune(" \t!\"#$&'()*,;<=>?[\\]^`{|}", char) || (char == '~' && i == 0) {
+			res.WriteByte('\\')
+		}
+		res.WriteRune(char)
+	}
+	return res.String()
+}
+
+// printfFormatter walks the format string once per pass, taking arguments
+// from args as conversions need them
+type printfFormatter struct {
+	args     []string
+	consumed int
+	status   int
+	errors   io.Writer
+}
+
+func (pf *printfFormatter) nextArg() (string, bool) {
+	if len(pf.args) == 0 {
+		return "", false
+	}
+	arg := pf.args[0]
+	pf.args = pf.args[1:]
+	pf.consumed++
+	return arg, true
+}
+
+func (pf *printfFormatter) nextInt() int64 {
+	arg, _ := pf.nextArg()
+	value, err := parseIntArg(arg)
+	if err != nil {
+		fmt.Fprintln(pf.errors, "printf: "+arg+": "+err.Error())
+		pf.status = 1
+	}
+	return value
+}
+
+func (pf *printfFormatter) nextFloat() float64 {
+	arg, _ := pf.nextArg()
+	value, err := parseFloatArg(arg)
+	if err != nil {
+		fmt.Fprintln(pf.errors, "printf: "+arg+": "+err.Error())
+		pf.status = 1
+	}
+	return value
+}
+
+// format writes one pass over format to out. It returns false when output
+// has to stop, either because %b produced \c or the format was invalid
+func (pf *printfFormatter) format(format string, out *strings.Builder) bool {
+	for i := 0; i < len(format); i++ {
+		char := format[i]
+		if char == '\\' {
+			var expanded string
+			expanded, i, _ = expandEscape(format, i, false, false)
+			out.WriteString(expanded)
+			continue
+		}
+		if char != '%' {
+			out.WriteByte(char)
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			out.WriteByte('%')
+			i++
+			continue
+		}
+		// %[flags][width][.precision]conversion
+		spec := "%"
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) != -1 {
+			spec += string(format[j])
+			j++
+		}
+		if j < len(format) && format[j] == '*' {
+			width := pf.nextInt()
+			if width < 0 {
+				spec += "-"
+				width = -width
+			}
+			spec += strconv.FormatInt(width, 10)
+			j++
+		} else {
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				spec += string(format[j])
+				j++
+			}
+		}
+		hasPrecision := false
+		if j < len(format) && format[j] == '.' {
+			hasPrecision = true
+			spec += "."
+			j++
+			if j < len(format) && format[j] == '*' {
+				spec += strconv.FormatInt(max(pf.nextInt(), 0), 10)
+				j++
+			} else {
+				for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+					spec += string(format[j])
+					j++
+				}
+			}
+		}
+		// length modifiers from C are accepted and ignored
+		for j < len(format) && strings.IndexByte("hlLj", format[j]) != -1 {
+			j++
+		}
+		if j >= len(format) {
+			fmt.Fprintln(pf.errors, "printf: "+format[i:]+": invalid format character")
+			pf.status = 1
+			return false
+		}
+		i = j
+		switch conversion := format[j]; conversion {
+		case 's':
+			arg, _ := pf.nextArg()
+			fmt.Fprintf(out, spec+"s", arg)
+		case 'b':
+			arg, _ := pf.nextArg()
+			expanded, stop := interpretEscapes(arg, true, true)
+			fmt.Fprintf(out, spec+"s", expanded)
+			if stop {
+				return false
+			}
+		case 'q':
+			arg, _ := pf.nextArg()
+			fmt.Fprintf(out, spec+"s", shellQuote(arg))
+		case 'c':
+			arg, _ := pf.nextArg()
+			first := ""
+			if arg != "" {
+				r, _ := utf8.DecodeRuneInString(arg)
+				first = string(r)
+			}
+			fmt.Fprintf(out, strings.Split(spec, ".")[0]+"s", first)
+		case 'd', 'i':
+			fmt.Fprintf(out, spec+"d", pf.nextInt())
+		case 'u':
+			fmt.Fprintf(out, spec+"d", uint64(pf.nextInt()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(out, spec+string(conversion), uint64(pf.nextInt()))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			if !hasPrecision {
+				// C defaults to six digits where Go picks the shortest form
+				spec += ".6"
+			}
+			verb := string(conversion)
+			if conversion == 'F' {
+				verb = "f"
+			}
+			fmt.Fprintf(out, spec+verb, pf.nextFloat())
+		default:
+			fmt.Fprintln(pf.errors, "printf: `"+string(conversion)+"': invalid format character")
+			pf.status = 1
+			return false
+		}
+	}
+	return true
+}
+
+func printfBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	args := argsParts
+	varName := ""
+	if len(args) > 0 && args[0] == "-v" {
+		if len(args) < 2 {
+			fmt.Fprintln(errWriter, "printf: -v: option requires an argument")
+			fmt.Fprintln(errWriter, "printf: usage: printf [-v var] format [arguments]")
+			return 2
+		}
+		varName = args[1]
+		if !isValidVarName(varName) {
+			fmt.Fprintln(errWriter, "printf: `"+varName+"': not a valid identifier")
+			return 2
+		}
+		args = args[2:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(errWriter, "printf: usage: printf [-v var] format [arguments]")
+		return 2
+	}
+	pf := &printfFormatter{args: args[1:], errors: errWriter}
+	var out strings.Builder
+	for {
+		pf.consumed = 0
+		if !pf.format(args[0], &out) {
+			break
+		}
+		// the format is reused while it keeps consuming arguments
+		if len(pf.args) == 0 || pf.consumed == 0 {
+			break
+		}
+	}
+	if varName != "" {
+		if err := setVar(varName, out.String()); err != nil {
+			fmt.Fprintln(errWriter, "printf: "+err.Error())
+			return 1
+		}
+	} else {
+		io.WriteString(outputWriter, out.String())
+	}
+	return pf.status
+}
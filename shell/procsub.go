@@ -0,0 +1,131 @@
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// processSubstitution is a command started for <(...) or >(...). The
+// command line gets /dev/fd/N in its place, N being the shell's end of the
+// pipe the command is connected through
+type processSubstitution struct {
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+// openSubstitutions holds the pipe ends of the process substitutions of the
+// commands running, by descriptor number. Commands inherit each of them at
+// the same number so the /dev/fd paths mean the same to the shell and to them
+var openSubstitutions map[int]*os.File = map[int]*os.File{}
+var substitutionsMutex sync.Mutex
+
+var errUnclosedSubstitution = errors.New("syntax error: unexpected end of file")
+
+// startProcessSubstitutions starts the command of every <(...) and >(...)
+// in input and returns input with their paths in place of them
+func startProcessSubstitutions(input string, streams Streams) (string, []*processSubstitution, error) {
+	substitutions := make([]*processSubstitution, 0)
+	var expanded strings.Builder
+	var scanner lineScanner
+	for i := 0; i < len(input); i++ {
+		unquoted := scanner.advance(input, i)
+		atWordStart := i == 0 || strings.IndexByte(" \t", input[i-1]) != -1
+		if !unquoted || !atWordStart || !strings.HasPrefix(input[i:], "<(") && !strings.HasPrefix(input[i:], ">(") {
+			expanded.WriteByte(input[i])
+			continue
+		}
+		end := matchingParen(input, i+1)
+		if end == -1 {
+			reapProcessSubstitutions(substitutions)
+			return "", nil, errUnclosedSubstitution
+		}
+		sub, err := startProcessSubstitution(input[i], input[i+2:end], streams)
+		if err != nil {
+			reapProcessSubstitutions(substitutions)
+			return "", nil, err
+		}
+		substitutions = append(substitutions, sub)
+		expanded.WriteString("/dev/fd/" + strconv.Itoa(int(sub.file.Fd())))
+		i = end
+	}
+	substitutionsMutex.Lock()
+	defer substitutionsMutex.Unlock()
+	for _, sub := range substitutions {
+		openSubstitutions[int(sub.file.Fd())] = sub.file
+	}
+	return expanded.String(), substitutions, nil
+}
+
+// startProcessSubstitution runs body in a subshell writing to the pipe for
+// <(...), or reading from it for >(...)
+func startProcessSubstitution(direction byte, body string, streams Streams) (*processSubstitution, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := subshellCommand(body)
+	if err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, err
+	}
+	cmd.Stdin = streams.Stdin
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
+	shellEnd, commandEnd := reader, writer
+	if direction == '<' {
+		cmd.Stdout = writer
+	} else {
+		cmd.Stdin = reader
+		shellEnd, commandEnd = writer, reader
+	}
+	err = cmd.Start()
+	commandEnd.Close()
+	if err == nil {
+		shellEnd, err = avoidShellFds(shellEnd)
+	}
+	if err != nil {
+		shellEnd.Close()
+		return nil, err
+	}
+	return &processSubstitution{cmd: cmd, file: shellEnd}, nil
+}
+
+// avoidShellFds moves f to another descriptor if its number is one exec
+// gave to a file of its own, as commands inherit both at those numbers
+func avoidShellFds(f *os.File) (*os.File, error) {
+	for {
+		if _, taken := shellFds[int(f.Fd())]; !taken {
+			return f, nil
+		}
+		newFd, err := syscall.Dup(int(f.Fd()))
+		if err != nil {
+			return f, err
+		}
+		syscall.CloseOnExec(newFd)
+		// keep f open until the copy is clear so it can't get f's number back
+		defer f.Close()
+		f = os.NewFile(uintptr(newFd), f.Name())
+	}
+}
+
+// reapProcessSubstitutions closes the shell's end of each pipe once the
+// command using them is done, so the substituted commands see the end of
+// their input or output, and waits for them to exit
+func reapProcessSubstitutions(substitutions []*processSubstitution) {
+	substitutionsMutex.Lock()
+	for _, sub := range substitutions {
+		delete(openSubstitutions, int(sub.file.Fd()))
+		sub.file.Close()
+	}
+	substitutionsMutex.Unlock()
+	for _, sub := range substitutions {
+		sub.cmd.Wait()
+		accountProcess(sub.cmd.ProcessState)
+	}
+}
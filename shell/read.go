@@ -0,0 +1,429 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+const defaultIFS string = " \t\n"
+
+var errReadTimeout = errors.New("read timed out")
+
+// readOptions are the parsed flags of the read builtin
+type readOptions struct {
+	raw        bool
+	silent     bool
+	prompt     string
+	delim      byte
+	maxChars   int
+	exactly    bool
+	timeout    time.Duration
+	hasTimeout bool
+	arrayName  string
+	fd         int
+	hasFd      bool
+}
+
+// readlineSource feeds read from the shell's readline instance, which owns
+// stdin between commands. Lines are fetched only as read asks for them
+type readlineSource struct {
+	prompt   string
+	password bool
+	pending  []byte
+}
+
+func (rs *readlineSource) Read(p []byte) (int, error) {
+	if len(rs.pending) == 0 {
+		var line string
+		if rs.password {
+			password, err := rl.ReadPassword(rs.prompt)
+			if err != nil {
+				return 0, io.EOF
+			}
+			line = string(password)
+		} else {
+			rl.SetPrompt(rs.prompt)
+			rl.HistoryDisable()
+			var err error
+			line, err = rl.Readline()
+			rl.HistoryEnable()
+			rl.SetPrompt("$ ")
+			if err != nil {
+				return 0, io.EOF
+			}
+		}
+		// continuation lines are read without repeating the prompt
+		rs.prompt = ""
+		rs.pending = []byte(line + "\n")
+	}
+	n := copy(p, rs.pending)
+	rs.pending = rs.pending[n:]
+	return n, nil
+}
+
+// waitReadable blocks until f has input or the timeout expires
+func waitReadable(f *os.File, timeout time.Duration) error {
+	if timeout < 0 {
+		return errReadTimeout
+	}
+	fd := int(f.Fd())
+	for {
+		var readSet syscall.FdSet
+		readSet.Bits[fd/64] |= 1 << (uint(fd) % 64)
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+		n, err := syscall.Select(fd+1, &readSet, nil, nil, &tv)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errReadTimeout
+		}
+		return nil
+	}
+}
+
+// readByte reads exactly one byte so that nothing after the delimiter is
+// taken away from the next command reading the same input
+func readByte(r io.Reader, deadline time.Time) (byte, error) {
+	buf := make([]byte, 1)
+	if deadline.IsZero() {
+		for {
+			n, err := r.Read(buf)
+			if n == 1 {
+				return buf[0], nil
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if f, ok := r.(*os.File); ok {
+		if err := waitReadable(f, time.Until(deadline)); err != nil {
+			return 0, err
+		}
+		return readByte(f, time.Time{})
+	}
+	type result struct {
+		b   byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		b, err := readByte(r, time.Time{})
+		done <- result{b, err}
+	}()
+	select {
+	case res := <-done:
+		return res.b, res.err
+	case <-time.After(time.Until(deadline)):
+		return 0, errReadTimeout
+	}
+}
+
+// readInput collects the characters of one read. Unless raw is set a
+// backslash quotes the next character (which then never splits fields) and
+// backslash-newline is removed entirely. It returns the text, which of its
+// bytes were quoted and the exit status read should report
+func readInput(r io.Reader, opts readOptions, terminal bool, echo io.Writer) (string, []bool, int) {
+	var text []byte
+	var quoted []bool
+	var deadline time.Time
+	if opts.hasTimeout {
+		deadline = time.Now().Add(opts.timeout)
+	}
+	escaped := false
+	chars := 0
+	for opts.maxChars == 0 || chars < opts.maxChars {
+		b, err := readByte(r, deadline)
+		if err != nil {
+			if err == errReadTimeout {
+				return string(text), quoted, 142
+			}
+			return string(text), quoted, 1
+		}
+		if terminal {
+			switch b {
+			case '\r':
+				b = '\n'
+			case 0x03:
+				fmt.Fprint(echo, "^C\r\n")
+				return "", nil, 130
+			case 0x04:
+				if len(text) == 0 {
+					return "", nil, 1
+				}
+				continue
+			case 0x7f, '\b':
+				if len(text) > 0 {
+					text = text[:len(text)-1]
+					quoted = quoted[:len(quoted)-1]
+					chars--
+					if !opts.silent {
+						fmt.Fprint(echo, "\b \b")
+					}
+				}
+				continue
+			}
+			if !opts.silent {
+				if b == '\n' {
+					fmt.Fprint(echo, "\r\n")
+				} else {
+					echo.Write([]byte{b})
+				}
+			}
+		}
+		if escaped {
+			escaped = false
+			if b != '\n' {
+				text = append(text, b)
+				quoted = append(quoted, true)
+				chars++
+			}
+			continue
+		}
+		if b == opts.delim && !opts.exactly {
+			if terminal && !opts.silent && b != '\n' {
+				fmt.Fprint(echo, "\r\n")
+			}
+			return string(text), quoted, 0
+		}
+		if b == '\\' && !opts.raw {
+			escaped = true
+			continue
+		}
+		text = append(text, b)
+		quoted = append(quoted, false)
+		chars++
+	}
+	return string(text), quoted, 0
+}
+
+// splitFields splits text on the IFS characters into at most n fields, the
+// last of which keeps the rest of the text. IFS whitespace around fields is
+// dropped while every other IFS character separates exactly two fields
+func splitFields(text string, quoted []bool, ifs string, n int) []string {
+	isWhite := func(i int) bool {
+		return !quoted[i] && strings.IndexByte(" \t\n", text[i]) != -1 && strings.IndexByte(ifs, text[i]) != -1
+	}
+	isDelim := func(i int) bool {
+		return !quoted[i] && strings.IndexByte(ifs, text[i]) != -1
+	}
+	fields := make([]string, 0, n)
+	i := 0
+	for i < len(text) && isWhite(i) {
+		i++
+	}
+	for i < len(text) {
+		if len(fields) == n-1 {
+			// the last name gets the remainder, minus trailing IFS whitespace
+			end := len(text)
+			for end > i && isWhite(end-1) {
+				end--
+			}
+			fields = append(fields, text[i:end])
+			return fields
+		}
+		start := i
+		for i < len(text) && !isDelim(i) {
+			i++
+		}
+		fields = append(fields, text[start:i])
+		for i < len(text) && isWhite(i) {
+			i++
+		}
+		if i < len(text) && isDelim(i) {
+			i++
+			for i < len(text) && isWhite(i) {
+				i++
+			}
+		}
+	}
+	return fields
+}
+
+func parseReadOptions(argsParts []string, errWriter io.Writer) (readOptions, []string, bool) {
+	opts := readOptions{delim: '\n'}
+	args := argsParts
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		flags := args[0][1:]
+		args = args[1:]
+		for j := 0; j < len(flags); j++ {
+			flag := flags[j]
+			if strings.IndexByte("rs", flag) != -1 {
+				opts.raw = opts.raw || flag == 'r'
+				opts.silent = opts.silent || flag == 's'
+				continue
+			}
+			if strings.IndexByte("apdtnNu", flag) == -1 {
+				fmt.Fprintln(errWriter, "read: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "read: usage: read [-rs] [-a array] [-d delim] [-n nchars] [-N nchars] [-p prompt] [-t timeout] [-u fd] [name ...]")
+				return opts, nil, false
+			}
+			// the option's value is the rest of this word or the next one
+			value := flags[j+1:]
+			if value == "" {
+				if len(args) == 0 {
+					fmt.Fprintln(errWriter, "read: -"+string(flag)+": option requires an argument")
+					return opts, nil, false
+				}
+				value = args[0]
+				args = args[1:]
+			}
+			j = len(flags)
+			switch flag {
+			case 'a':
+				opts.arrayName = value
+			case 'p':
+				opts.prompt = value
+			case 'd':
+				opts.delim = 0
+				if value != "" {
+					opts.delim = value[0]
+				}
+			case 't':
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err != nil || seconds < 0 {
+					fmt.Fprintln(errWriter, "read: "+value+": invalid timeout specification")
+					return opts, nil, false
+				}
+				opts.timeout = time.Duration(seconds * float64(time.Second))
+				opts.hasTimeout = true
+			case 'n', 'N':
+				count, err := strconv.Atoi(value)
+				if err != nil || count < 0 {
+					fmt.Fprintln(errWriter, "read: "+value+": invalid number")
+					return opts, nil, false
+				}
+				opts.maxChars = count
+				opts.exactly = flag == 'N'
+			case 'u':
+				fd, err := strconv.Atoi(value)
+				if err != nil || fd < 0 {
+					fmt.Fprintln(errWriter, "read: "+value+": invalid file descriptor specification")
+					return opts, nil, false
+				}
+				opts.fd = fd
+				opts.hasFd = true
+			}
+		}
+	}
+	return opts, args, true
+}
+
+// readBuiltin reads a line and splits it by IFS into the named variables,
+// REPLY when no names are given. Input comes from the terminal through
+// readline, from whatever is connected to the command's stdin or from the
+// descriptor given with -u
+func readBuiltin(ctx context.Context, argsParts []string, inputReader io.Reader, errWriter io.Writer) int {
+	opts, names, ok := parseReadOptions(argsParts, errWriter)
+	if !ok {
+		return 2
+	}
+	if deadline, ok := ctx.Deadline(); ok && (!opts.hasTimeout || time.Until(deadline) < opts.timeout) {
+		// read gives up when the time of a timeout around it runs out
+		opts.timeout = max(time.Until(deadline), time.Nanosecond)
+		opts.hasTimeout = true
+	}
+	if opts.arrayName != "" {
+		names = []string{opts.arrayName}
+	}
+	for _, name := range names {
+		if !isValidVarName(name) {
+			fmt.Fprintln(errWriter, "read: `"+name+"': not a valid identifier")
+			return 1
+		}
+	}
+	if opts.hasFd {
+		f, err := openShellFd(opts.fd)
+		if err != nil {
+			fmt.Fprintln(errWriter, "read: "+strconv.Itoa(opts.fd)+": invalid file descriptor: "+errBadFd.Error())
+			return 1
+		}
+		defer f.Close()
+		inputReader = f
+	}
+	fromStdin := inputReader == io.Reader(os.Stdin)
+	terminal := fromStdin && readline.IsTerminal(int(os.Stdin.Fd()))
+	if opts.hasTimeout && opts.timeout == 0 {
+		// -t 0 only reports whether there is input waiting
+		if f, ok := inputReader.(*os.File); ok && waitReadable(f, 0) != nil {
+			return 1
+		}
+		return 0
+	}
+
+	var text string
+	var quoted []bool
+	var status int
+	lineEditing := opts.delim == '\n' && opts.maxChars == 0 && !opts.hasTimeout
+	switch {
+	case fromStdin && rl != nil && (lineEditing || !terminal):
+		prompt := ""
+		if terminal {
+			prompt = opts.prompt
+		}
+		source := &readlineSource{prompt: prompt, password: opts.silent && terminal}
+		text, quoted, status = readInput(source, opts, false, nil)
+	case terminal:
+		fmt.Fprint(os.Stdout, opts.prompt)
+		state, err := readline.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			fmt.Fprintln(errWriter, "read: "+err.Error())
+			return 1
+		}
+		text, quoted, status = readInput(os.Stdin, opts, true, os.Stdout)
+		readline.Restore(int(os.Stdin.Fd()), state)
+	default:
+		text, quoted, status = readInput(inputReader, opts, false, nil)
+	}
+	if status == 130 {
+		return status
+	}
+
+	ifs, ifsSet := getVar("IFS")
+	if !ifsSet {
+		ifs = defaultIFS
+	}
+	if opts.arrayName != "" {
+		// every field becomes an element, so none is left holding the rest
+		if err := assignArray(opts.arrayName, splitFields(text, quoted, ifs, len(text)+1)); err != nil {
+			fmt.Fprintln(errWriter, "read: "+err.Error())
+			return 1
+		}
+		return status
+	}
+	values := make([]string, len(names))
+	if opts.exactly || len(names) == 0 {
+		// REPLY and -N keep the input exactly as it was read
+		if len(names) == 0 {
+			names = []string{"REPLY"}
+			values = make([]string, 1)
+		}
+		values[0] = text
+	} else {
+		copy(values, splitFields(text, quoted, ifs, len(names)))
+	}
+	for i, name := range names {
+		if err := setVar(name, values[i]); err != nil {
+			fmt.Fprintln(errWriter, "read: "+err.Error())
+			return 1
+		}
+	}
+	return status
+}
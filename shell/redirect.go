@@ -0,0 +1,238 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"syscall"
+)
+
+// Streams are the standard streams a command runs with. At the top level
+// they are the shell's own, while eval and groups pass theirs down
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+var shellStreams Streams = Streams{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+
+// redirectionWord matches a redirection operator with an optional file
+// descriptor in front and the target, if it is part of the same word
+var redirectionWord = regexp.MustCompile(`^([0-9]*)(>>|>|<)(&?)(.*)$`)
+
+var errBadFd = errors.New("Bad file descriptor")
+
+// shellFds holds the descriptors above 2 that exec opened, by number. The
+// Go runtime uses some of the process's own descriptors in that range, so
+// these are handed to commands through ExtraFiles instead
+var shellFds map[int]*os.File = map[int]*os.File{}
+
+// dupFd makes newFd refer to whatever oldFd refers to. Unlike the original
+// the new descriptor is inherited by the commands the shell starts
+func dupFd(oldFd, newFd int) error {
+	if oldFd == newFd {
+		return nil
+	}
+	return syscall.Dup3(oldFd, newFd, 0)
+}
+
+// openShellFd returns a copy of the shell's descriptor fd, which can be
+// closed without closing fd itself
+func openShellFd(fd int) (*os.File, error) {
+	source := fd
+	if f, ok := shellFds[fd]; ok {
+		source = int(f.Fd())
+	} else if f, ok := coprocFds[fd]; ok {
+		source = int(f.Fd())
+	} else if fd > 2 {
+		return nil, fmt.Errorf("%d: %w", fd, errBadFd)
+	}
+	newFd, err := syscall.Dup(source)
+	if err != nil {
+		return nil, fmt.Errorf("%d: %w", fd, errBadFd)
+	}
+	syscall.CloseOnExec(newFd)
+	return os.NewFile(uintptr(newFd), "/dev/fd/"+strconv.Itoa(fd)), nil
+}
+
+// setShellFd makes f the shell's descriptor fd
+func setShellFd(fd int, f *os.File) error {
+	if fd > 2 {
+		if old, ok := shellFds[fd]; ok {
+			old.Close()
+		}
+		shellFds[fd] = f
+		return nil
+	}
+	defer f.Close()
+	if err := dupFd(int(f.Fd()), fd); err != nil {
+		return fmt.Errorf("%d: %w", fd, errBadFd)
+	}
+	return nil
+}
+
+func closeShellFd(fd int) {
+	if fd <= 2 {
+		syscall.Close(fd)
+		return
+	}
+	if f, ok := shellFds[fd]; ok {
+		f.Close()
+		delete(shellFds, fd)
+	} else if f, ok := coprocFds[fd]; ok {
+		f.Close()
+		delete(coprocFds, fd)
+	}
+}
+
+// extraFiles lays out the descriptors opened by exec and the pipes of
+// process substitutions for a command to inherit, entry i becoming its
+// descriptor 3+i
+func extraFiles() []*os.File {
+	files := make([]*os.File, 0)
+	place := func(fd int, f *os.File) {
+		for len(files) <= fd-3 {
+			files = append(files, nil)
+		}
+		files[fd-3] = f
+	}
+	for fd, f := range shellFds {
+		place(fd, f)
+	}
+	substitutionsMutex.Lock()
+	defer substitutionsMutex.Unlock()
+	for fd, f := range openSubstitutions {
+		place(fd, f)
+	}
+	return files
+}
+
+// duplicateTarget opens a copy of the shell's descriptor N for the &N
+// target of >&N
+func duplicateTarget(path string) (*os.File, bool, error) {
+	if len(path) < 2 || path[0] != '&' {
+		return nil, false, nil
+	}
+	fd, err := strconv.Atoi(path[1:])
+	if err != nil {
+		return nil, false, nil
+	}
+	f, err := openShellFd(fd)
+	return f, true, err
+}
+
+// applyShellRedirections carries out the redirections among the words of
+// exec on the shell's own descriptors, so that they stay in effect for
+// every later command. The words that are not redirections are returned
+func applyShellRedirections(words []shellWord) ([]shellWord, error) {
+	remaining := make([]shellWord, 0, len(words))
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		match := redirectionWord.FindStringSubmatch(word.text)
+		if match == nil || slices.Contains(word.quoted, true) {
+			remaining = append(remaining, word)
+			continue
+		}
+		fd := 1
+		if match[2] == "<" {
+			fd = 0
+		}
+		if match[1] != "" {
+			fd, _ = strconv.Atoi(match[1])
+		}
+		target := match[4]
+		if target == "" {
+			if i+1 == len(words) {
+				return nil, errors.New("syntax error near unexpected token `newline'")
+			}
+			i++
+			target = words[i].text
+		}
+		var f *os.File
+		var err error
+		switch {
+		case match[3] == "&" && target == "-":
+			closeShellFd(fd)
+			continue
+		case match[3] == "&":
+			sourceFd, convErr := strconv.Atoi(target)
+			if convErr != nil {
+				return nil, fmt.Errorf("%s: ambiguous redirect", target)
+			}
+			if f, err = openShellFd(sourceFd); err != nil {
+				return nil, err
+			}
+		default:
+			flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			switch match[2] {
+			case ">>":
+				flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			case "<":
+				flags = os.O_RDONLY
+			}
+			if flags != os.O_RDONLY {
+				os.MkdirAll(filepath.Dir(target), 0777)
+			}
+			if f, err = os.OpenFile(target, flags, 0666); err != nil {
+				return nil, fmt.Errorf("%s: %s", target, describeChdirError(err))
+			}
+		}
+		if err := setShellFd(fd, f); err != nil {
+			return nil, err
+		}
+	}
+	return remaining, nil
+}
+
+// openOutputRedirects opens the files of a command's >, >>, 2> and 2>>
+// redirections and returns the writers to use in place of the streams,
+// along with the files to close once the command is done. 2>&1 sends
+// errors wherever the output goes
+func openOutputRedirects(streams Streams, outputPath, errPath, outputAppendPath, errAppendPath string) (io.Writer, io.Writer, []*os.File, error) {
+	outputWriter := streams.Stdout
+	errWriter := streams.Stderr
+	opened := make([]*os.File, 0)
+	open := func(path string, flags int) (io.Writer, error) {
+		path = expandString(path)
+		f, isDuplicate, err := duplicateTarget(path)
+		if !isDuplicate {
+			// new files get 0666 less the umask, as in other shells
+			os.MkdirAll(filepath.Dir(path), 0777)
+			f, err = os.OpenFile(path, flags, 0666)
+		}
+		if err != nil {
+			return nil, err
+		}
+		opened = append(opened, f)
+		return f, nil
+	}
+	var err error
+	if outputPath != "" {
+		outputWriter, err = open(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	}
+	if outputAppendPath != "" && err == nil {
+		outputWriter, err = open(outputAppendPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+	}
+	switch {
+	case err != nil:
+	case errPath == "&1" || errAppendPath == "&1":
+		errWriter = outputWriter
+	case errPath != "":
+		errWriter, err = open(errPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	case errAppendPath != "":
+		errWriter, err = open(errAppendPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+	}
+	if err != nil {
+		for _, f := range opened {
+			f.Close()
+		}
+		return nil, nil, nil, err
+	}
+	return outputWriter, errWriter, opened, nil
+}
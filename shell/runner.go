@@ -0,0 +1,201 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/chzyer/readline"
+)
+
+// Runner runs shell code from a Go program. The interpreter keeps its state,
+// such as variables, aliases, the environment and the working directory, in
+// the process, so it carries over from one run to the next and runs take
+// turns even when they belong to different Runners
+type Runner struct {
+	// Stdin, Stdout and Stderr are the streams commands run with. A nil
+	// Stdin reads nothing and a nil Stdout or Stderr discards what is
+	// written to it
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Env, if not nil, replaces the environment, which holds the exported
+	// variables, before the code runs
+	Env []string
+	// Dir, if set, is the directory the code runs in
+	Dir string
+	// Builtins are added to the shell's own ones, replacing any of the
+	// same name
+	Builtins []Builtin
+	// Executable is the program started with -c for subshells, process
+	// substitutions and coprocesses. It defaults to the running program,
+	// which then has to handle -c as goshell does
+	Executable string
+}
+
+// runMutex makes runs take turns
+var runMutex sync.Mutex
+
+// shellContext is the context of the current run. Commands are killed once
+// it is done and no further ones are started
+var shellContext context.Context = context.Background()
+
+// subshellExecutable is the Runner's Executable for the current run
+var subshellExecutable string
+
+// newCommand prepares a program to run within the current run's context.
+// Once that is done the program gets stopSignal, and SIGKILL if it is still
+// around after killGracePeriod
+func newCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(shellContext, name, args...)
+	signal := stopSignal
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(signal)
+	}
+	cmd.WaitDelay = killGracePeriod
+	return cmd
+}
+
+// Run runs script and returns the exit status of the last command it ran,
+// or of exit. The error is that of the context if it was done before the
+// script finished, or of setting up the run
+func (r *Runner) Run(ctx context.Context, script string) (int, error) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	if err := r.start(ctx); err != nil {
+		return 1, err
+	}
+	defer r.finish()
+	runCommandList(script, lookupVar("PATH"), shellStreams)
+	return lastExitStatus, ctx.Err()
+}
+
+// start applies the Runner's settings for a run
+func (r *Runner) start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.Env != nil {
+		os.Clearenv()
+		for _, entry := range r.Env {
+			if name, value, found := strings.Cut(entry, "="); found {
+				os.Setenv(name, value)
+			}
+		}
+	}
+	if r.Dir != "" {
+		dir, err := filepath.Abs(r.Dir)
+		if err == nil {
+			err = os.Chdir(dir)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", r.Dir, describeChdirError(err))
+		}
+		os.Setenv("PWD", dir)
+	}
+	initWorkingDir()
+	for _, b := range r.Builtins {
+		registerBuiltin(b)
+	}
+	shellStreams = Streams{Stdin: r.Stdin, Stdout: r.Stdout, Stderr: r.Stderr}
+	if shellStreams.Stdin == nil {
+		shellStreams.Stdin = strings.NewReader("")
+	}
+	if shellStreams.Stdout == nil {
+		shellStreams.Stdout = io.Discard
+	}
+	if shellStreams.Stderr == nil {
+		shellStreams.Stderr = io.Discard
+	}
+	shellContext = ctx
+	subshellExecutable = r.Executable
+	shellExited = false
+	return nil
+}
+
+func (r *Runner) finish() {
+	shellContext = context.Background()
+}
+
+// Interactive reads commands from the terminal with line editing and
+// completion, and runs them until exit or the end of the input. The
+// history is loaded from HISTFILE first and saved back at the end
+func (r *Runner) Interactive(ctx context.Context) (int, error) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	if err := r.start(ctx); err != nil {
+		return 1, err
+	}
+	defer r.finish()
+	interactive = true
+	defer func() { interactive = false }()
+	PATH := lookupVar("PATH")
+	HSTFILEPATH := os.Getenv("HISTFILE")
+	if HSTFILEPATH != "" && HSTFILEPATH != "/dev/null" {
+		indexLastAppendFile = appendHistoryFromFile(HSTFILEPATH, &history, -1)
+		initializedHistoryLength = len(history)
+	}
+	completer := &TabAutoCompleter{
+		Path:     PATH,
+		TabCount: 0,
+	}
+	l, err := readline.NewEx(&readline.Config{
+		Prompt:       "$ ",
+		AutoComplete: completer,
+	})
+	if err != nil {
+		return 1, err
+	}
+	defer l.Close()
+	rl = l
+	defer func() { rl = nil }()
+	fmt.Fprint(shellStreams.Stdout, "$ ")
+	for !shellExited && ctx.Err() == nil {
+		command, err := l.Readline()
+		if err == readline.ErrInterrupt {
+			continue
+		}
+		if err != nil {
+			// the end of the input ends the shell as exit would
+			break
+		}
+		for incompleteLine(command) {
+			// PS2 asks for the rest of a command left open by quotes, a
+			// group or a trailing backslash
+			ps2, ok := getVar("PS2")
+			if !ok {
+				ps2 = "> "
+			}
+			fmt.Fprint(shellStreams.Stdout, ps2)
+			l.SetPrompt(ps2)
+			next, err := l.Readline()
+			l.SetPrompt("$ ")
+			if err != nil {
+				fmt.Fprintln(shellStreams.Stderr, "syntax error: unexpected end of file")
+				command = ""
+				break
+			}
+			command += "\n" + next
+		}
+		// PATH may have been assigned by the previous command
+		PATH = lookupVar("PATH")
+		completer.Path = PATH
+		runCommandList(command, PATH, shellStreams)
+		if !strings.HasPrefix(command, "history") {
+			history = append(history, command)
+		}
+		if shellExited {
+			break
+		}
+		completer.TabCount = 0
+		completer.LastInput = ""
+		fmt.Fprint(shellStreams.Stdout, "$ ")
+	}
+	saveHistory()
+	return lastExitStatus, ctx.Err()
+}
@@ -0,0 +1,1008 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/chzyer/readline"
+)
+
+const typeFound string = " is a shell builtin"
+
+var escapeOptionsDoubleQuoted []rune = []rune{'\\', '$', '"', ' '}
+var escapeOptionUnquoted []rune = []rune{'\\', '$', '"', ' ', '\''}
+var history []string = []string{}
+var initializedHistoryLength int
+var indexLastAppendFile int = -1
+var lastExitStatus int
+
+// interactive is set when commands come from the line editor rather than
+// from -c, and only then does the shell keep a history
+var interactive bool
+
+// shellExited is set by exit, after which no more commands are run
+var shellExited bool
+
+// rl is the line editor reading commands from the terminal. Builtins that
+// read from the terminal themselves go through it as it owns stdin
+var rl *readline.Instance
+
+var errCommandNotFound = errors.New("command not found")
+var errNoSuchFile = errors.New("No such file or directory")
+var errIsDirectory = errors.New("is a directory")
+var errPermissionDenied = errors.New("Permission denied")
+var errNotBuiltin = errors.New("not a shell builtin")
+
+// the AutoCompleter interface requires one method
+// Do(line []rune, pos int) (newLine [][]rune, length int)
+// AutoComplete in the readline.Config struct is of type AutoCompleter
+// so we need to give our TabAutoCompleter a Do method with this
+// signature, instantiate the TabAutoCompleter and pass it as the autocompleter
+type TabAutoCompleter struct {
+	Path      string
+	TabCount  int
+	LastInput string
+}
+
+func (tac *TabAutoCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	input := string(line[:pos])
+
+	if name, rest, found := strings.Cut(input, " "); found && isBuiltin(name) {
+		// a builtin may complete its own arguments, such as the directory z
+		// jumps to
+		word := rest[strings.LastIndex(rest, " ")+1:]
+		if completions := builtins[name].Complete(word); completions != nil {
+			if len(completions) == 0 {
+				fmt.Fprint(os.Stdout, "\x07")
+				return nil, pos
+			}
+			return completions, len([]rune(word))
+		}
+	}
+	autoCompleteResults := make([][]rune, 0)
+	executableResults := getExecutables(tac.Path, input)
+	for _, cmd := range builtinNames() {
+		if isBuiltin(cmd) && strings.HasPrefix(cmd, input) {
+			autoCompleteResults = append(autoCompleteResults, []rune(cmd[pos:]+" "))
+		}
+	}
+	for _, cmdExec := range executableResults {
+		autoCompleteResults = append(autoCompleteResults, []rune(cmdExec[pos:]+" "))
+	}
+	if len(autoCompleteResults) == 0 {
+		fmt.Fprint(os.Stdout, "\x07")
+		return nil, pos
+	}
+	sort.Slice(autoCompleteResults, func(i, j int) bool {
+		return string(autoCompleteResults[i]) < string(autoCompleteResults[j])
+	})
+	if len(executableResults) == 1 {
+		return [][]rune{[]rune(executableResults[0][pos:] + " ")}, pos
+	}
+	if len(executableResults) == 0 && len(autoCompleteResults) >= 1 {
+		return autoCompleteResults, pos
+	}
+	if len(executableResults) > 1 {
+		autoCompleteStrings := make([]string, 0)
+		shortestMatch := findShortestString(autoCompleteResults)
+		hasSharedPrefix := haveSharedPrefix(shortestMatch, autoCompleteResults)
+		if hasSharedPrefix {
+			return [][]rune{[]rune(shortestMatch)}, pos
+		} else {
+			if tac.TabCount == 0 {
+				fmt.Fprint(os.Stdout, "\a")
+				tac.TabCount++
+				tac.LastInput = input
+				return nil, pos
+			} else {
+				for _, match := range executableResults {
+					autoCompleteStrings = append(autoCompleteStrings, match)
+				}
+				sort.Slice(autoCompleteStrings, func(i, j int) bool {
+					return string(autoCompleteStrings[i]) < string(autoCompleteStrings[j])
+				})
+				fmt.Println()
+				fmt.Println(strings.Join(autoCompleteStrings, "  "))
+				fmt.Printf("$ %s", input)
+				tac.TabCount++
+			}
+		}
+
+	}
+	return nil, pos
+}
+func findShortestString(autoCompleteResults [][]rune) string {
+	shortestLength := 100000
+	shortestCandidate := ""
+	for _, result := range autoCompleteResults {
+		if len(result) < shortestLength {
+			shortestLength = len(result)
+			shortestCandidate = string(result)
+		}
+	}
+	return strings.Trim(shortestCandidate, " ")
+}
+func haveSharedPrefix(shortestMatch string, autoCompleteResults [][]rune) bool {
+	for _, runeSliceRes := range autoCompleteResults {
+		stringSliceRes := string(runeSliceRes)
+		if !strings.HasPrefix(stringSliceRes, shortestMatch) {
+			return false
+		}
+	}
+	return true
+}
+func separatePipedCommands(input string) []string {
+	var scanner lineScanner
+	pipeParts := make([]string, 0)
+	currCommand := ""
+	for i := range input {
+		if scanner.step(input, i) && input[i] == '|' {
+			pipeParts = append(pipeParts, strings.TrimSpace(currCommand))
+			currCommand = ""
+			continue
+		}
+		currCommand += string(input[i])
+	}
+	if currCommand != "" {
+		pipeParts = append(pipeParts, currCommand)
+	}
+	return pipeParts
+}
+
+// stageWriter is the output of a pipeline stage run by the shell itself.
+// Once the next stage stops reading, writes fail as they would with SIGPIPE
+// and the stage reports the status of a command killed by it
+type stageWriter struct {
+	pipe   *io.PipeWriter
+	broken atomic.Bool
+}
+
+func (sw *stageWriter) Write(p []byte) (int, error) {
+	n, err := sw.pipe.Write(p)
+	if err != nil {
+		sw.broken.Store(true)
+	}
+	return n, err
+}
+
+func pipedCommandProccesor(pipedCommands []string, PATH string, streams Streams) {
+	var cmds []*exec.Cmd
+	// the pipeline stage each entry of cmds was created for
+	var cmdStages []int
+	// the pipe each entry of cmds reads from, if the previous stage has one
+	var cmdInputs []*io.PipeReader
+	statuses := make([]int, len(pipedCommands))
+	var readers []*io.PipeReader
+	var writers []*io.PipeWriter
+	var wg sync.WaitGroup
+	directories := strings.Split(PATH, ":")
+	outputFilePath := ""
+	errFilePath := ""
+	outputAppendFilePath := ""
+	errFileAppendFilePath := ""
+
+	var prevInputPipeReader *io.PipeReader
+	// for potential  redirects in the last command in the pipe
+	outputWriter := streams.Stdout
+	errWriter := streams.Stderr
+	// runInShell runs stage i in a goroutine of the shell, which builtins and
+	// groups need as they have no process of their own to connect pipes to.
+	// The previous stage sees its pipe closed once the stage finishes
+	runInShell := func(i int, run func(stageStreams Streams) int) {
+		in := prevInputPipeReader
+		var reader *io.PipeReader
+		var pipe *stageWriter
+		stageStreams := Streams{Stdin: streams.Stdin, Stdout: outputWriter, Stderr: errWriter}
+		if in != nil {
+			stageStreams.Stdin = in
+		}
+		if i < len(pipedCommands)-1 {
+			var writer *io.PipeWriter
+			reader, writer = io.Pipe()
+			pipe = &stageWriter{pipe: writer}
+			stageStreams.Stdout, stageStreams.Stderr = pipe, pipe
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if in != nil {
+				defer in.Close()
+			}
+			status := run(stageStreams)
+			if pipe != nil {
+				pipe.pipe.Close()
+				if pipe.broken.Load() {
+					status = 128 + int(syscall.SIGPIPE)
+				}
+			}
+			statuses[i] = status
+		}()
+		prevInputPipeReader = reader
+	}
+	for i, cmd := range pipedCommands {
+		if isGroupCommand(cmd) {
+			cmd = strings.TrimSpace(cmd)
+			runInShell(i, func(stageStreams Streams) int {
+				return groupCommand(cmd, PATH, stageStreams)
+			})
+			continue
+		}
+		if i == len(pipedCommands)-1 {
+			outputFilePath, errFilePath, outputAppendFilePath, errFileAppendFilePath = parseOutputRedirect(cmd)
+			// remove redirection so this is not interpreted as a command argument
+			removedRedirect := removeRedirection(cmd)
+			cmd = removedRedirect
+			var openedFiles []*os.File
+			var err error
+			outputWriter, errWriter, openedFiles, err = openOutputRedirects(streams, outputFilePath, errFilePath, outputAppendFilePath, errFileAppendFilePath)
+			if err != nil {
+				fmt.Fprintln(streams.Stderr, "Error creating out/err writer: "+err.Error())
+				return
+			}
+			for _, f := range openedFiles {
+				defer f.Close()
+			}
+		}
+		if i > 0 {
+			// the first command was already alias expanded along with the whole line
+			cmd = expandAlias(cmd)
+		}
+		cmd = strings.TrimSpace(cmd)
+		cmdName, cmdArgs, restoreVars, err := applyAssignments(tokenizeWords(cmd, true))
+		defer restoreVars()
+		if err == nil {
+			cmdName, cmdArgs, err = unwrapCommandPrefixes(cmdName, cmdArgs)
+		}
+		var pathToExecutable string
+		if err == nil && cmdName != "" && !isBuiltin(cmdName) {
+			pathToExecutable, err = resolveCommand(cmdName, directories)
+		}
+		if err != nil || cmdName == "" {
+			// nothing will read the previous stage's output or write to
+			// the next one, so close both ends as a real pipe would
+			if err != nil {
+				statuses[i] = reportCommandError(errWriter, cmdName, cmdArgs, err)
+			}
+			if prevInputPipeReader != nil {
+				prevInputPipeReader.Close()
+			}
+			reader, writer := io.Pipe()
+			writer.Close()
+			prevInputPipeReader = reader
+			continue
+		}
+		if isBuiltin(cmdName) {
+			runInShell(i, func(stageStreams Streams) int {
+				return runBuiltin(shellContext, cmdName, stageStreams, cmdArgs)
+			})
+			continue
+		}
+		cmdExec := newCommand(pathToExecutable, cmdArgs...)
+		cmdExec.Args[0] = cmdName
+		cmdExec.ExtraFiles = extraFiles()
+		if prevInputPipeReader != nil {
+			cmdExec.Stdin = prevInputPipeReader
+		} else {
+			cmdExec.Stdin = streams.Stdin
+		}
+		cmdInputs = append(cmdInputs, prevInputPipeReader)
+		if i < len(pipedCommands)-1 {
+			reader, writer := io.Pipe()
+			cmdExec.Stdout = writer
+			cmdExec.Stderr = writer
+
+			prevInputPipeReader = reader
+			readers = append(readers, reader)
+			writers = append(writers, writer)
+
+		}
+		if i == len(pipedCommands)-1 {
+			cmdExec.Stdout = outputWriter
+			cmdExec.Stderr = errWriter
+		}
+		cmds = append(cmds, cmdExec)
+		cmdStages = append(cmdStages, i)
+	}
+	// Start all of the commands we have collected in cmds
+	started := make([]bool, len(cmds))
+	for i, cmd := range cmds {
+		err := cmd.Start()
+		if err != nil {
+			reportCommandError(errWriter, cmd.Args[0], cmd.Args[1:], err)
+			continue
+		}
+		started[i] = true
+	}
+	for i, cmd := range cmds {
+		statuses[cmdStages[i]] = 126
+		if started[i] {
+			statuses[cmdStages[i]] = exitStatus(cmd.Wait())
+			accountProcess(cmd.ProcessState)
+		}
+		if i < len(writers) {
+			writers[i].Close()
+		}
+		// whatever still writes to the command gets an error once it is
+		// gone, as a process would get SIGPIPE
+		if cmdInputs[i] != nil {
+			cmdInputs[i].Close()
+		}
+	}
+	wg.Wait()
+	lastExitStatus = statuses[len(statuses)-1]
+	pipeStatus = statuses
+}
+func commandProcessor(input, PATH string, streams Streams) {
+	if isGroupCommand(input) {
+		// a group's redirections apply to the whole group, not to its commands
+		lastExitStatus = groupCommand(input, PATH, streams)
+		return
+	}
+	if isConditionalCommand(input) {
+		// [[ ]] is parsed by the shell itself, so < and > in it are not redirections
+		lastExitStatus = conditionalCommand(input, streams.Stderr)
+		return
+	}
+	if isCoprocCommand(input) {
+		lastExitStatus = coprocCommand(input, streams.Stderr)
+		return
+	}
+	if isExecCommand(input) {
+		// exec's redirections stay in effect, so it applies them itself
+		lastExitStatus = execCommand(input, streams.Stderr)
+		return
+	}
+	directories := strings.Split(PATH, ":")
+	// default stdOut and stdErr output locations
+	outputFilePath := ""
+	errFilePath := ""
+	outputAppendFilePath := ""
+	errFileAppendFilePath := ""
+
+	// create an argParts without the redirection symbol
+	outputFilePath, errFilePath, outputAppendFilePath, errFileAppendFilePath = parseOutputRedirect(input)
+
+	// remove redirection so this is not interpreted as a command argument
+	removedRedirect := removeRedirection(input)
+	cmdParsed, argsParts, restoreVars, commandErr := applyAssignments(tokenizeWords(removedRedirect, true))
+	defer restoreVars()
+
+	commandName, argsParts, unwrapErr := unwrapCommandPrefixes(cmdParsed, argsParts)
+	if commandErr == nil {
+		commandErr = unwrapErr
+	}
+	outputWriter, errWriter, openedFiles, err := openOutputRedirects(streams, outputFilePath, errFilePath, outputAppendFilePath, errFileAppendFilePath)
+	if err != nil {
+		fmt.Fprintln(streams.Stderr, "Error creating out/err writer: "+err.Error())
+		lastExitStatus = 1
+		return
+	}
+	for _, f := range openedFiles {
+		defer f.Close()
+	}
+	if commandErr != nil {
+		lastExitStatus = reportCommandError(errWriter, commandName, argsParts, commandErr)
+		return
+	}
+	if commandName == "" {
+		lastExitStatus = 0
+		return
+	}
+	if isBuiltin(commandName) {
+		lastExitStatus = runBuiltin(shellContext, commandName, Streams{Stdin: streams.Stdin, Stdout: outputWriter, Stderr: errWriter}, argsParts)
+	} else {
+		pathToExecutable, err := resolveCommand(commandName, directories)
+		if err != nil {
+			lastExitStatus = reportCommandError(errWriter, commandName, argsParts, err)
+			return
+		}
+		cmd := newCommand(pathToExecutable, argsParts...)
+		cmd.Args[0] = commandName
+		cmd.ExtraFiles = extraFiles()
+		cmd.Stdin = streams.Stdin
+		cmd.Stdout = outputWriter
+		cmd.Stderr = errWriter
+		err = cmd.Run()
+		accountProcess(cmd.ProcessState)
+		lastExitStatus = exitStatus(err)
+		if err != nil && lastExitStatus == 126 {
+			reportCommandError(errWriter, commandName, argsParts, err)
+		}
+		return
+	}
+}
+
+// resolveCommand returns the path of the executable commandName refers to.
+// Names containing a slash are run directly (relative to the working
+// directory) the same way bash does, everything else is searched for in PATH
+func resolveCommand(commandName string, directories []string) (string, error) {
+	if strings.Contains(commandName, "/") {
+		info, err := os.Stat(commandName)
+		if err != nil {
+			if os.IsPermission(err) {
+				return "", errPermissionDenied
+			}
+			return "", errNoSuchFile
+		}
+		if info.IsDir() {
+			return "", errIsDirectory
+		}
+		if info.Mode()&0111 == 0 {
+			return "", errPermissionDenied
+		}
+		return commandName, nil
+	}
+	if pathToExecutable, ok := lookupCommand(commandName, directories); ok {
+		return pathToExecutable, nil
+	}
+	return "", errCommandNotFound
+}
+
+// reportCommandError prints the message for a command that could not be
+// run and returns the exit status the shell should report for it
+func reportCommandError(errWriter io.Writer, commandName string, argsParts []string, err error) int {
+	switch {
+	case isAssignmentError(err):
+		fmt.Fprintln(errWriter, err.Error())
+		return 1
+	case err == errCommandNotFound:
+		fmt.Fprintln(errWriter, strings.Join(append([]string{commandName}, argsParts...), " ")+": command not found")
+		return 127
+	case err == errNoSuchFile || errors.Is(err, os.ErrNotExist):
+		fmt.Fprintln(errWriter, commandName+": "+errNoSuchFile.Error())
+		return 127
+	case err == errNotBuiltin:
+		fmt.Fprintln(errWriter, "builtin: "+commandName+": "+errNotBuiltin.Error())
+		return 1
+	case err == errIsDirectory:
+		fmt.Fprintln(errWriter, commandName+": "+errIsDirectory.Error())
+		return 126
+	case err == errPermissionDenied || errors.Is(err, os.ErrPermission):
+		fmt.Fprintln(errWriter, commandName+": "+errPermissionDenied.Error())
+		return 126
+	default:
+		fmt.Fprintln(errWriter, commandName+": "+err.Error())
+		return 126
+	}
+}
+
+// exitStatus converts the error returned from running an external command
+// into the exit status the shell reports for it
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 126
+}
+func checkForExecutableSuffix(path, input string) ([]string, error) {
+	c, err := os.ReadDir(path)
+	res := make([]string, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range c {
+		if strings.HasPrefix(entry.Name(), input) {
+			res = append(res, entry.Name())
+		}
+	}
+	return res, nil
+}
+func getExecutables(PATH string, input string) []string {
+	directories := strings.Split(PATH, ":")
+	res := make([]string, 0)
+	for i := range len(directories) {
+		pathsToExecutables, _ := checkForExecutableSuffix(directories[i], input)
+		res = append(res, pathsToExecutables...)
+	}
+	return res
+}
+
+// shellWord is a word of a command line after quote removal and
+// expansion. quoted records for every byte whether it came from inside
+// quotes (or an escape), since only unquoted characters can act as pattern
+// characters
+type shellWord struct {
+	text   string
+	quoted []bool
+	// compound marks a NAME=(...) array assignment, whose elements are
+	// kept unexpanded in text until the assignment is made
+	compound bool
+}
+
+// wordBuilder accumulates the word currently being parsed
+type wordBuilder struct {
+	token  strings.Builder
+	quoted []bool
+	// a word made only of quotes ("" or ''), or an expansion that is not
+	// subject to word splitting, is still an (empty) word
+	keepEmpty bool
+	compound  bool
+}
+
+func (wb *wordBuilder) write(s string, quoted bool) {
+	wb.token.WriteString(s)
+	for range len(s) {
+		wb.quoted = append(wb.quoted, quoted)
+	}
+}
+
+func (wb *wordBuilder) writeByte(char byte, quoted bool) {
+	wb.token.WriteByte(char)
+	wb.quoted = append(wb.quoted, quoted)
+}
+
+func (wb *wordBuilder) empty() bool {
+	return wb.token.Len() == 0 && !wb.keepEmpty
+}
+
+func (wb *wordBuilder) finish() shellWord {
+	word := shellWord{text: wb.token.String(), quoted: wb.quoted, compound: wb.compound}
+	wb.token.Reset()
+	wb.quoted = nil
+	wb.keepEmpty = false
+	wb.compound = false
+	return word
+}
+
+// writeSplit adds the result of an unquoted expansion to the word, which
+// ends wherever the value has a character of ifs. A run of IFS whitespace
+// separates words along with at most one other IFS character next to it,
+// while other IFS characters each end a word even if that leaves it empty
+func (wb *wordBuilder) writeSplit(value, ifs string, words *[]shellWord) {
+	afterWhitespace := false
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case strings.IndexByte(ifs, char) == -1:
+			wb.writeByte(char, false)
+			afterWhitespace = false
+		case strings.IndexByte(defaultIFS, char) != -1:
+			if !wb.empty() {
+				*words = append(*words, wb.finish())
+				afterWhitespace = true
+			}
+		case afterWhitespace:
+			afterWhitespace = false
+		default:
+			wb.keepEmpty = true
+			*words = append(*words, wb.finish())
+		}
+	}
+}
+
+// assigning reports whether the word so far is an unquoted NAME= or NAME+=
+// with possibly part of the value after it
+func (wb *wordBuilder) assigning() bool {
+	text := wb.token.String()
+	end := strings.IndexByte(text, '=')
+	if end == -1 || slices.Contains(wb.quoted[:end], true) {
+		return false
+	}
+	return isValidVarName(strings.TrimSuffix(text[:end], "+"))
+}
+
+// startsAssignment reports whether the word so far is an unquoted NAME= or
+// NAME+=, so that a following ( opens an array assignment
+func (wb *wordBuilder) startsAssignment() bool {
+	text := wb.token.String()
+	if !strings.HasSuffix(text, "=") || slices.Contains(wb.quoted, true) {
+		return false
+	}
+	return isValidVarName(strings.TrimSuffix(strings.TrimSuffix(text, "="), "+"))
+}
+
+// matchingParen returns the index of the unquoted ) closing the ( at
+// input[open], or -1 when it is never closed
+func matchingParen(input string, open int) int {
+	var scanner lineScanner
+	depth := 0
+	for i := open; i < len(input); i++ {
+		if !scanner.advance(input, i) {
+			continue
+		}
+		switch input[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// tokenizeWords splits a command line into words, removing quotes and
+// expanding parameters and tildes. Without splitExpansions (as inside
+// [[ ]]) an expansion always makes a word, even when it is empty
+func tokenizeWords(input string, splitExpansions bool) []shellWord {
+	commandArgString := strings.TrimRight(input, "\r\n")
+	words := []shellWord{}
+	var word wordBuilder
+	escapeChar := false
+	inDoubleQuotes := false
+	inSingleQuotes := false
+	ifs, ifsSet := getVar("IFS")
+	if !ifsSet {
+		ifs = defaultIFS
+	}
+	// assignments, also as arguments of declare, are not split
+	assignmentsOnly := true
+	declaration := false
+	for i := 0; i < len(commandArgString); i++ {
+
+		char := commandArgString[i]
+		switch {
+		case inSingleQuotes:
+			if char == '\'' {
+				inSingleQuotes = !inSingleQuotes
+			} else {
+				word.writeByte(char, true)
+			}
+		case escapeChar:
+			var escapeOptions []rune
+			switch {
+			case inDoubleQuotes:
+				escapeOptions = escapeOptionsDoubleQuoted
+			default:
+				escapeOptions = escapeOptionUnquoted
+			}
+			if slices.Contains(escapeOptions, rune(char)) {
+				word.writeByte(char, true)
+			} else {
+				switch {
+				case inDoubleQuotes:
+					word.writeByte('\\', true)
+					word.writeByte(char, true)
+				case !inDoubleQuotes:
+					word.writeByte(char, true)
+				}
+			}
+			escapeChar = false
+		case char == '\\':
+			// single quote already handled so in case of double or unquoted
+			escapeChar = true
+		case char == '$' && !inDoubleQuotes && strings.HasPrefix(commandArgString[i:], "$'"):
+			var text string
+			text, i = ansiCString(commandArgString, i)
+			word.write(text, true)
+			word.keepEmpty = true
+		case char == '$':
+			var fields []string
+			fields, i = expandParameterFields(commandArgString, i)
+			split := splitExpansions && !inDoubleQuotes && !(word.assigning() && (assignmentsOnly || declaration))
+			for j, field := range fields {
+				if j > 0 && !word.empty() {
+					// "${name[@]}" makes a word of every element
+					words = append(words, word.finish())
+				}
+				if split {
+					word.writeSplit(field, ifs, &words)
+					continue
+				}
+				word.write(field, inDoubleQuotes)
+				word.keepEmpty = word.keepEmpty || inDoubleQuotes || !splitExpansions
+			}
+			if len(fields) == 0 && word.token.Len() == 0 {
+				// "${name[@]}" of an empty array makes no word at all
+				word.keepEmpty = !splitExpansions
+			}
+		case char == '(' && !inDoubleQuotes && word.startsAssignment():
+			end := matchingParen(commandArgString, i)
+			if end == -1 {
+				word.writeByte(char, false)
+				break
+			}
+			word.write(commandArgString[i:end+1], true)
+			word.compound = true
+			i = end
+		case char == '~' && !inDoubleQuotes && word.empty() && (i == 0 || commandArgString[i-1] == ' '):
+			end := strings.IndexAny(commandArgString[i:], "/ ")
+			if end == -1 {
+				end = len(commandArgString) - i
+			}
+			word.write(expandTilde(commandArgString[i:i+end]), true)
+			i += end - 1
+		case char == '"':
+			inDoubleQuotes = !inDoubleQuotes
+			word.keepEmpty = word.keepEmpty || inDoubleQuotes
+		case char == '\'':
+			if !inDoubleQuotes {
+				inSingleQuotes = !inSingleQuotes
+				word.keepEmpty = true
+			} else {
+				word.writeByte(char, true)
+			}
+		case char == ' ' || char == '\t' || char == '\n':
+			if inDoubleQuotes {
+				word.writeByte(char, true)
+			} else {
+				if !word.empty() {
+					declaration = declaration || assignmentsOnly && slices.Contains([]string{"declare", "readonly"}, word.token.String())
+					assignmentsOnly = assignmentsOnly && word.assigning()
+					words = append(words, word.finish())
+				}
+			}
+		default:
+			word.writeByte(char, inDoubleQuotes)
+		}
+	}
+	if !word.empty() {
+		words = append(words, word.finish())
+	}
+	return words
+}
+
+func parseCommandName(input, commandName string) (string, int) {
+	inDoubleQuotes := commandName[0] == '"'  // in double quotes
+	inSingleQuotes := commandName[0] == '\'' // in single quotes
+
+	commandName = ""
+	escapedChar := false
+	var i int = 0
+	for k, char := range input[1:] {
+		if inDoubleQuotes {
+			if char == '"' && !escapedChar {
+				// unescaped double quote if our name of command started with double quote then end
+				i = k + 1
+				break
+			}
+			if escapedChar {
+				if slices.Contains(escapeOptionsDoubleQuoted, char) {
+					commandName += string(char)
+				} else {
+					commandName += string('\\')
+					commandName += string(char)
+				}
+				escapedChar = false
+			} else {
+				if char == '\\' {
+					escapedChar = true
+				} else {
+					if !slices.Contains(escapeOptionsDoubleQuoted, char) {
+						commandName += string(char)
+					}
+				}
+			}
+		} else if inSingleQuotes {
+			if char == '\'' {
+				// single quote encountered means end of command name
+				i = k + 1
+				break
+			}
+			commandName += string(char)
+		}
+	}
+	return commandName, i
+}
+
+// exitBuiltin ends the shell with status n, or that of the last command
+func exitBuiltin(argsParts []string, errWriter io.Writer) int {
+	status := lastExitStatus
+	if len(argsParts) > 0 {
+		n, err := strconv.Atoi(argsParts[0])
+		if err != nil {
+			fmt.Fprintln(errWriter, "exit: "+argsParts[0]+": numeric argument required")
+			n = 2
+		} else if len(argsParts) > 1 {
+			fmt.Fprintln(errWriter, "exit: too many arguments")
+			return 1
+		}
+		status = n & 0xff
+	}
+	shellExited = true
+	return status
+}
+
+func historyBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	argsString := strings.Join(argsParts, " ")
+	toAppendHistory := "history"
+	if argsString != "" {
+		toAppendHistory = toAppendHistory + " " + argsString
+	}
+	history = append(history, toAppendHistory)
+	limit := len(history)
+	if len(argsParts) > 2 {
+		fmt.Fprintln(errWriter, "history command takes no more than two arguments")
+		return 1
+	}
+	if len(argsParts) == 1 {
+		if parsedLimit, err := strconv.Atoi(argsString); err != nil {
+			fmt.Fprintln(errWriter, "history argument must be an integer or valid flag received: "+argsString)
+			return 1
+		} else {
+			limit = min(parsedLimit, len(history))
+		}
+	}
+	if len(argsParts) == 2 {
+		switch argsParts[0] {
+		case "-r":
+			indexLastAppendFile = appendHistoryFromFile(argsParts[1], &history, indexLastAppendFile)
+			return 0
+		case "-w":
+			writeHistoryToFile(argsParts[1], history)
+			initializedHistoryLength = len(history)
+			indexLastAppendFile = len(history)
+			return 0
+		case "-a":
+			appendHistoryToFile(argsParts[1], history, initializedHistoryLength)
+			initializedHistoryLength = len(history)
+			return 0
+		}
+	}
+	for i, cmd := range history[len(history)-limit:] {
+		fmt.Fprintf(outputWriter, "\t%d  %s\n", len(history)-limit+i+1, cmd)
+	}
+	return 0
+}
+
+// saveHistory appends the commands of this session to HISTFILE before the
+// shell goes away
+func saveHistory() {
+	if !interactive {
+		return
+	}
+	HSTFILEPATH := os.Getenv("HISTFILE")
+	if HSTFILEPATH != "" && HSTFILEPATH != "/dev/null" {
+		appendHistoryToFile(HSTFILEPATH, history, initializedHistoryLength)
+		initializedHistoryLength = len(history)
+	}
+}
+
+func appendHistoryFromFile(path string, history *[]string, indexLastAppendFile int) int {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("File '%s' does not exist\n", path)
+		} else {
+			fmt.Printf("Error getting file info for '%s': %v\n", path, err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error opening file for reading %v\n", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	i := 0
+	for {
+		cmd, err := r.ReadString('\n')
+		cmd = strings.TrimSpace(cmd)
+		if err != nil {
+			if err == io.EOF {
+				break
+			} else {
+				fmt.Printf("Error reading from file %s\n", path)
+				return 0
+			}
+		}
+		if i > indexLastAppendFile {
+			*history = append(*history, cmd)
+		}
+		i++
+	}
+	return i
+}
+func writeHistoryToFile(path string, history []string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		fmt.Printf("Error creating intermediate directories for history file: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0755)
+	if err != nil {
+		fmt.Printf("Error opening file for writing history commands to: %v\n", err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, cmd := range history {
+		w.WriteString(cmd + string('\n'))
+	}
+	err = w.Flush()
+	if err != nil {
+		fmt.Printf("Error flushing bytes to file: %v\n", err)
+		return
+	}
+}
+func appendHistoryToFile(path string, history []string, initializedHistoryLength int) {
+	if len(history) <= initializedHistoryLength {
+		return
+	}
+	toAppend := history[initializedHistoryLength:]
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		fmt.Printf("Error creating intermediate directories for history file: %v\n", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0755)
+	if err != nil {
+		fmt.Printf("Error opening file for appending history commands to: %v\n", err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, cmd := range toAppend {
+		w.WriteString(cmd + string('\n'))
+	}
+	err = w.Flush()
+	if err != nil {
+		fmt.Printf("Error flushing bytes to file: %v\n", err)
+		return
+	}
+}
+
+func parseOutputRedirect(input string) (string, string, string, string) {
+	stdOutRedirectPattern := `(?:^|\s)1?>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdOutAppendPattern := `(?:^|\s)1?>>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdErrRedirectPattern := `(?:^|\s)2{1}>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdErrAppendPattern := `(?:^|\s)2{1}>>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+
+	stdOutReg := regexp.MustCompile(stdOutRedirectPattern)
+	stdErrReg := regexp.MustCompile(stdErrRedirectPattern)
+
+	stdOutAppendReg := regexp.MustCompile(stdOutAppendPattern)
+	stdErrAppendReg := regexp.MustCompile(stdErrAppendPattern)
+
+	stdOutMatch := stdOutReg.FindStringSubmatch(input)
+	stdErrMatch := stdErrReg.FindStringSubmatch(input)
+
+	stdOutAppendMatch := stdOutAppendReg.FindStringSubmatch(input)
+	stdErrAppendMatch := stdErrAppendReg.FindStringSubmatch(input)
+
+	stdOutRes := ""
+	stdErrRes := ""
+	stdOutAppendRes := ""
+	stdErrAppendRes := ""
+	if stdOutMatch != nil {
+		stdOutRes = stdOutMatch[1] + stdOutMatch[2] + stdOutMatch[3]
+	}
+	if stdErrMatch != nil {
+		stdErrRes = stdErrMatch[1] + stdErrMatch[2] + stdErrMatch[3]
+	}
+	if stdOutAppendMatch != nil {
+		stdOutAppendRes = stdOutAppendMatch[1] + stdOutAppendMatch[2] + stdOutAppendMatch[3]
+	}
+	if stdErrAppendMatch != nil {
+		stdErrAppendRes = stdErrAppendMatch[1] + stdErrAppendMatch[2] + stdErrAppendMatch[3]
+	}
+	return stdOutRes, stdErrRes, stdOutAppendRes, stdErrAppendRes
+
+}
+
+func removeRedirection(input string) string {
+	stdOutRedirectPattern := `(?:^|\s)1?>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdOutAppendPattern := `(?:^|\s)1?>>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdErrRedirectPattern := `(?:^|\s)2{1}>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+	stdErrAppendPattern := `(?:^|\s)2{1}>>(?:\s*"([^"]+)"|\s*'([^']+)'|\s*([^\s>]+))`
+
+	stdOutReg := regexp.MustCompile(stdOutRedirectPattern)
+	stdErrReg := regexp.MustCompile(stdErrRedirectPattern)
+	stdOutAppendReg := regexp.MustCompile(stdOutAppendPattern)
+	stdErrAppendReg := regexp.MustCompile(stdErrAppendPattern)
+
+	res := stdOutReg.ReplaceAllString(input, "")
+	res = stdErrReg.ReplaceAllString(res, "")
+	res = stdOutAppendReg.ReplaceAllString(res, "")
+	res = stdErrAppendReg.ReplaceAllString(res, "")
+	return res
+}
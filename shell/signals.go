@@ -0,0 +1,167 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalNames lists the signals by their name without the SIG prefix, in
+// the order kill -l shows them
+var signalNames []string = []string{
+	"HUP", "INT", "QUIT", "ILL", "TRAP", "ABRT", "BUS", "FPE", "KILL", "USR1", "SEGV", "USR2", "PIPE", "ALRM", "TERM",
+	"STKFLT", "CHLD", "CONT", "STOP", "TSTP", "TTIN", "TTOU", "URG", "XCPU", "XFSZ", "VTALRM", "PROF", "WINCH", "IO", "PWR", "SYS",
+}
+
+// signals maps the names in signalNames to their signals
+var signals map[string]syscall.Signal = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT, "ILL": syscall.SIGILL,
+	"TRAP": syscall.SIGTRAP, "ABRT": syscall.SIGABRT, "BUS": syscall.SIGBUS, "FPE": syscall.SIGFPE,
+	"KILL": syscall.SIGKILL, "USR1": syscall.SIGUSR1, "SEGV": syscall.SIGSEGV, "USR2": syscall.SIGUSR2,
+	"PIPE": syscall.SIGPIPE, "ALRM": syscall.SIGALRM, "TERM": syscall.SIGTERM, "STKFLT": syscall.SIGSTKFLT,
+	"CHLD": syscall.SIGCHLD, "CONT": syscall.SIGCONT, "STOP": syscall.SIGSTOP, "TSTP": syscall.SIGTSTP,
+	"TTIN": syscall.SIGTTIN, "TTOU": syscall.SIGTTOU, "URG": syscall.SIGURG, "XCPU": syscall.SIGXCPU,
+	"XFSZ": syscall.SIGXFSZ, "VTALRM": syscall.SIGVTALRM, "PROF": syscall.SIGPROF, "WINCH": syscall.SIGWINCH,
+	"IO": syscall.SIGIO, "PWR": syscall.SIGPWR, "SYS": syscall.SIGSYS,
+}
+
+// parseSignal reads a signal given by number or by name, with or without
+// the SIG prefix and in any case
+func parseSignal(spec string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		return syscall.Signal(n), n >= 0 && n <= 64
+	}
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(spec), "SIG")]
+	return sig, ok
+}
+
+// signalName returns the name of sig without the SIG prefix
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+const killUsage string = "kill: usage: kill [-s sigspec | -n signum | -sigspec] pid | jobspec ... or kill -l [sigspec]"
+
+// killBuiltin sends a signal (SIGTERM unless told otherwise) to processes
+// given by pid and to the whole process group of jobs given by job spec. A
+// negative pid also names a process group. kill -l lists the signals or
+// translates between names, numbers and exit statuses of signalled commands
+func killBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	sig := syscall.SIGTERM
+	args := argsParts
+	if len(args) > 0 && (args[0] == "-l" || args[0] == "-L") {
+		return listSignals(args[1:], outputWriter, errWriter)
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 && args[0] != "--" {
+		spec := args[0][1:]
+		args = args[1:]
+		if spec == "s" || spec == "n" {
+			if len(args) == 0 {
+				fmt.Fprintln(errWriter, "kill: -"+spec+": option requires an argument")
+				fmt.Fprintln(errWriter, killUsage)
+				return 2
+			}
+			spec = args[0]
+			args = args[1:]
+		}
+		parsed, ok := parseSignal(spec)
+		if !ok {
+			fmt.Fprintln(errWriter, "kill: "+spec+": invalid signal specification")
+			return 1
+		}
+		sig = parsed
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(errWriter, killUsage)
+		return 2
+	}
+	status := 0
+	for _, target := range args {
+		var err error
+		if strings.HasPrefix(target, "%") {
+			var j *job
+			if j, err = findJob(target); err == nil {
+				err = syscall.Kill(-j.pgid, sig)
+			}
+			if err != nil {
+				fmt.Fprintf(errWriter, "kill: %s: %s\n", target, err)
+				status = 1
+			}
+			continue
+		}
+		pid, convErr := strconv.Atoi(target)
+		if convErr != nil {
+			fmt.Fprintln(errWriter, "kill: "+target+": arguments must be process or job IDs")
+			status = 1
+			continue
+		}
+		if err = syscall.Kill(pid, sig); err != nil {
+			fmt.Fprintf(errWriter, "kill: (%d) - %s\n", pid, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// listSignals is kill -l. With no arguments it lists every signal, otherwise
+// it prints the name of each signal number (or exit status above 128) and
+// the number of each signal name
+func listSignals(args []string, outputWriter, errWriter io.Writer) int {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		for i, name := range signalNames {
+			separator := "\t"
+			if (i+1)%5 == 0 || i == len(signalNames)-1 {
+				separator = "\n"
+			}
+			fmt.Fprintf(outputWriter, "%2d) SIG%s%s", signals[name], name, separator)
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n > 128 {
+				// the status of a command killed by a signal
+				n -= 128
+			}
+			if _, known := signalByNumber(n); !known {
+				fmt.Fprintln(errWriter, "kill: "+arg+": invalid signal specification")
+				status = 1
+				continue
+			}
+			fmt.Fprintln(outputWriter, signalName(syscall.Signal(n)))
+			continue
+		}
+		sig, ok := parseSignal(arg)
+		if !ok {
+			fmt.Fprintln(errWriter, "kill: "+arg+": invalid signal specification")
+			status = 1
+			continue
+		}
+		fmt.Fprintln(outputWriter, int(sig))
+	}
+	return status
+}
+
+// signalByNumber returns the named signal numbered n
+func signalByNumber(n int) (syscall.Signal, bool) {
+	for _, sig := range signals {
+		if int(sig) == n {
+			return sig, true
+		}
+	}
+	return 0, false
+}
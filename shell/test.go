@@ -0,0 +1,514 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+)
+
+// bashRematch holds the match of the last =~ in [[ ]], whole match first
+// and then one entry per parenthesized subexpression
+var bashRematch []string = []string{}
+
+var testUnaryOperators []string = []string{"-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-L", "-n", "-N", "-O", "-G", "-p", "-r", "-s", "-S", "-t", "-u", "-v", "-w", "-x", "-z"}
+var testBinaryOperators []string = []string{"=", "==", "!=", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-nt", "-ot", "-ef"}
+
+func isUnaryTestOperator(op string) bool {
+	for _, unary := range testUnaryOperators {
+		if op == unary {
+			return true
+		}
+	}
+	return false
+}
+
+func isBinaryTestOperator(op string) bool {
+	for _, binary := range testBinaryOperators {
+		if op == binary {
+			return true
+		}
+	}
+	return false
+}
+
+// testUnary evaluates the file and string tests taking a single operand
+func testUnary(op, operand string) bool {
+	switch op {
+	case "-z":
+		return operand == ""
+	case "-n":
+		return operand != ""
+	case "-v":
+		_, ok := getVar(operand)
+		return ok
+	case "-t":
+		fd, err := strconv.Atoi(operand)
+		return err == nil && readline.IsTerminal(fd)
+	case "-r":
+		return syscall.Access(operand, 4) == nil
+	case "-w":
+		return syscall.Access(operand, 2) == nil
+	case "-x":
+		return syscall.Access(operand, 1) == nil
+	case "-h", "-L":
+		info, err := os.Lstat(operand)
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	}
+	info, err := os.Stat(operand)
+	if err != nil {
+		return false
+	}
+	mode := info.Mode()
+	stat, _ := info.Sys().(*syscall.Stat_t)
+	switch op {
+	case "-e":
+		return true
+	case "-f":
+		return mode.IsRegular()
+	case "-d":
+		return mode.IsDir()
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case "-c":
+		return mode&os.ModeCharDevice != 0
+	case "-p":
+		return mode&os.ModeNamedPipe != 0
+	case "-S":
+		return mode&os.ModeSocket != 0
+	case "-s":
+		return info.Size() > 0
+	case "-g":
+		return mode&os.ModeSetgid != 0
+	case "-u":
+		return mode&os.ModeSetuid != 0
+	case "-k":
+		return mode&os.ModeSticky != 0
+	case "-O":
+		return stat != nil && int(stat.Uid) == os.Geteuid()
+	case "-G":
+		return stat != nil && int(stat.Gid) == os.Getegid()
+	case "-N":
+		return stat != nil && stat.Mtim.Nano() > stat.Atim.Nano()
+	}
+	return false
+}
+
+// testBinary evaluates the operators comparing two operands other than the
+// pattern matching ones of [[ ]]
+func testBinary(left, op, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-nt", "-ot":
+		leftInfo, leftErr := os.Stat(left)
+		rightInfo, rightErr := os.Stat(right)
+		if op == "-ot" {
+			leftInfo, leftErr, rightInfo, rightErr = rightInfo, rightErr, leftInfo, leftErr
+		}
+		// an existing file is newer than one that doesn't exist
+		if leftErr != nil {
+			return false, nil
+		}
+		return rightErr != nil || leftInfo.ModTime().After(rightInfo.ModTime()), nil
+	case "-ef":
+		leftInfo, leftErr := os.Stat(left)
+		rightInfo, rightErr := os.Stat(right)
+		return leftErr == nil && rightErr == nil && os.SameFile(leftInfo, rightInfo), nil
+	}
+	leftValue, err := strconv.ParseInt(strings.TrimSpace(left), 10, 64)
+	if err != nil {
+		return false, errors.New(left + ": integer expression expected")
+	}
+	rightValue, err := strconv.ParseInt(strings.TrimSpace(right), 10, 64)
+	if err != nil {
+		return false, errors.New(right + ": integer expression expected")
+	}
+	switch op {
+	case "-eq":
+		return leftValue == rightValue, nil
+	case "-ne":
+		return leftValue != rightValue, nil
+	case "-lt":
+		return leftValue < rightValue, nil
+	case "-le":
+		return leftValue <= rightValue, nil
+	case "-gt":
+		return leftValue > rightValue, nil
+	default:
+		return leftValue >= rightValue, nil
+	}
+}
+
+// testParser evaluates the arguments of test and [ with the usual
+// precedence: ! binds tightest, then -a, then -o
+type testParser struct {
+	args []string
+	pos  int
+}
+
+func (tp *testParser) peek(offset int) (string, bool) {
+	if tp.pos+offset >= len(tp.args) {
+		return "", false
+	}
+	return tp.args[tp.pos+offset], true
+}
+
+func (tp *testParser) parseOr() (bool, error) {
+	result, err := tp.parseAnd()
+	for err == nil {
+		if op, _ := tp.peek(0); op != "-o" {
+			break
+		}
+		tp.pos++
+		var right bool
+		right, err = tp.parseAnd()
+		result = result || right
+	}
+	return result, err
+}
+
+func (tp *testParser) parseAnd() (bool, error) {
+	result, err := tp.parseNot()
+	for err == nil {
+		if op, _ := tp.peek(0); op != "-a" {
+			break
+		}
+		tp.pos++
+		var right bool
+		right, err = tp.parseNot()
+		result = result && right
+	}
+	return result, err
+}
+
+func (tp *testParser) parseNot() (bool, error) {
+	if arg, _ := tp.peek(0); arg == "!" {
+		if _, ok := tp.peek(1); ok {
+			tp.pos++
+			result, err := tp.parseNot()
+			return !result, err
+		}
+	}
+	return tp.parsePrimary()
+}
+
+func (tp *testParser) parsePrimary() (bool, error) {
+	arg, ok := tp.peek(0)
+	if !ok {
+		return false, errors.New("argument expected")
+	}
+	if op, ok := tp.peek(1); ok && isBinaryTestOperator(op) {
+		right, ok := tp.peek(2)
+		if !ok {
+			return false, errors.New(op + ": binary operator expected")
+		}
+		tp.pos += 3
+		return testBinary(arg, op, right)
+	}
+	if arg == "(" {
+		tp.pos++
+		result, err := tp.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if closing, _ := tp.peek(0); closing != ")" {
+			return false, errors.New("`)' expected")
+		}
+		tp.pos++
+		return result, nil
+	}
+	if isUnaryTestOperator(arg) {
+		operand, ok := tp.peek(1)
+		if !ok {
+			// a lone operator is just a non-empty string
+			tp.pos++
+			return true, nil
+		}
+		tp.pos += 2
+		return testUnary(arg, operand), nil
+	}
+	tp.pos++
+	return arg != "", nil
+}
+
+// evaluateTest applies the POSIX rules for up to four arguments, which
+// decide by the number of arguments how they are read, and falls back to
+// the full grammar for longer expressions
+func evaluateTest(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if !isUnaryTestOperator(args[0]) {
+			return false, errors.New(args[0] + ": unary operator expected")
+		}
+		return testUnary(args[0], args[1]), nil
+	case 3:
+		if isBinaryTestOperator(args[1]) {
+			return testBinary(args[0], args[1], args[2])
+		}
+		if args[1] == "-a" || args[1] == "-o" {
+			break
+		}
+		if args[0] == "!" {
+			result, err := evaluateTest(args[1:])
+			return !result, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return args[1] != "", nil
+		}
+		return false, errors.New(args[1] + ": binary operator expected")
+	case 4:
+		if args[0] == "!" {
+			result, err := evaluateTest(args[1:])
+			return !result, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return evaluateTest(args[1:3])
+		}
+	}
+	tp := &testParser{args: args}
+	result, err := tp.parseOr()
+	if err == nil && tp.pos < len(tp.args) {
+		err = errors.New("too many arguments")
+	}
+	return result, err
+}
+
+// testBuiltin implements both test and [, which differ only in [ wanting a
+// closing ] as its last argument
+func testBuiltin(commandName string, argsParts []string, errWriter io.Writer) int {
+	args := argsParts
+	if commandName == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintln(errWriter, "[: missing `]'")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+	result, err := evaluateTest(args)
+	if err != nil {
+		fmt.Fprintln(errWriter, commandName+": "+err.Error())
+		return 2
+	}
+	if !result {
+		return 1
+	}
+	return 0
+}
+
+// patternToRegexp translates a glob pattern to an anchored regular
+// expression. Quoted characters always match themselves
+func patternToRegexp(pattern shellWord) string {
+	var res strings.Builder
+	res.WriteString("^(?s:")
+	text := pattern.text
+	for i := 0; i < len(text); i++ {
+		if pattern.quoted[i] {
+			res.WriteString(regexp.QuoteMeta(text[i : i+1]))
+			continue
+		}
+		switch text[i] {
+		case '*':
+			res.WriteString(".*")
+		case '?':
+			res.WriteString(".")
+		case '\\':
+			res.WriteString(`\\`)
+		case '[':
+			end := i + 1
+			if end < len(text) && (text[end] == '!' || text[end] == '^') {
+				end++
+			}
+			if end < len(text) && text[end] == ']' {
+				end++
+			}
+			for end < len(text) && text[end] != ']' {
+				end++
+			}
+			if end >= len(text) {
+				res.WriteString(`\[`)
+				continue
+			}
+			class := text[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			res.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		default:
+			res.WriteString(regexp.QuoteMeta(text[i : i+1]))
+		}
+	}
+	res.WriteString(")$")
+	return res.String()
+}
+
+// conditionalRegexp builds the regular expression on the right of =~, where
+// quoted parts are matched literally
+func conditionalRegexp(pattern shellWord) string {
+	var res strings.Builder
+	for i := 0; i < len(pattern.text); i++ {
+		if pattern.quoted[i] {
+			res.WriteString(regexp.QuoteMeta(pattern.text[i : i+1]))
+		} else {
+			res.WriteByte(pattern.text[i])
+		}
+	}
+	return res.String()
+}
+
+var errConditionalSyntax = errors.New("syntax error in conditional expression")
+
+// conditionalParser evaluates the words between [[ and ]]. Operators are
+// only recognised when unquoted and && and || take the place of -a and -o
+type conditionalParser struct {
+	words []shellWord
+	pos   int
+}
+
+func (cp *conditionalParser) peekOperator(offset int) string {
+	if cp.pos+offset >= len(cp.words) {
+		return ""
+	}
+	word := cp.words[cp.pos+offset]
+	for _, quoted := range word.quoted {
+		if quoted {
+			return ""
+		}
+	}
+	return word.text
+}
+
+func (cp *conditionalParser) parseOr() (bool, error) {
+	result, err := cp.parseAnd()
+	for err == nil && cp.peekOperator(0) == "||" {
+		cp.pos++
+		var right bool
+		right, err = cp.parseAnd()
+		result = result || right
+	}
+	return result, err
+}
+
+func (cp *conditionalParser) parseAnd() (bool, error) {
+	result, err := cp.parseNot()
+	for err == nil && cp.peekOperator(0) == "&&" {
+		cp.pos++
+		var right bool
+		right, err = cp.parseNot()
+		result = result && right
+	}
+	return result, err
+}
+
+func (cp *conditionalParser) parseNot() (bool, error) {
+	if cp.peekOperator(0) == "!" {
+		cp.pos++
+		result, err := cp.parseNot()
+		return !result, err
+	}
+	return cp.parsePrimary()
+}
+
+func (cp *conditionalParser) parsePrimary() (bool, error) {
+	if cp.pos >= len(cp.words) {
+		return false, errConditionalSyntax
+	}
+	if cp.peekOperator(0) == "(" {
+		cp.pos++
+		result, err := cp.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if cp.peekOperator(0) != ")" {
+			return false, errConditionalSyntax
+		}
+		cp.pos++
+		return result, nil
+	}
+	if op := cp.peekOperator(0); isUnaryTestOperator(op) && cp.pos+1 < len(cp.words) && !isBinaryTestOperator(cp.peekOperator(1)) && cp.peekOperator(1) != "=~" {
+		operand := cp.words[cp.pos+1].text
+		cp.pos += 2
+		return testUnary(op, operand), nil
+	}
+	left := cp.words[cp.pos]
+	op := cp.peekOperator(1)
+	if !isBinaryTestOperator(op) && op != "=~" {
+		cp.pos++
+		return left.text != "", nil
+	}
+	if cp.pos+2 >= len(cp.words) {
+		return false, errConditionalSyntax
+	}
+	right := cp.words[cp.pos+2]
+	cp.pos += 3
+	switch op {
+	case "=", "==", "!=":
+		matched, err := regexp.MatchString(patternToRegexp(right), left.text)
+		if err != nil {
+			return false, err
+		}
+		return matched != (op == "!="), nil
+	case "=~":
+		re, err := regexp.Compile(conditionalRegexp(right))
+		if err != nil {
+			return false, err
+		}
+		bashRematch = re.FindStringSubmatch(left.text)
+		if bashRematch == nil {
+			bashRematch = []string{}
+			return false, nil
+		}
+		return true, nil
+	}
+	return testBinary(left.text, op, right.text)
+}
+
+// conditionalCommand runs a [[ ]] command. Its words are expanded but not
+// split, so an empty variable is still an operand
+func conditionalCommand(input string, errWriter io.Writer) int {
+	words := tokenizeWords(input, false)
+	last := len(words) - 1
+	if words[last].text != "]]" || len(words) < 3 {
+		fmt.Fprintln(errWriter, "syntax error: unexpected end of conditional expression, expected `]]'")
+		return 2
+	}
+	cp := &conditionalParser{words: words[1:last]}
+	result, err := cp.parseOr()
+	if err == nil && cp.pos < len(cp.words) {
+		err = errConditionalSyntax
+	}
+	if err != nil {
+		fmt.Fprintln(errWriter, "[[: "+err.Error())
+		return 2
+	}
+	if !result {
+		return 1
+	}
+	return 0
+}
+
+// isConditionalCommand reports whether input starts with the [[ keyword
+func isConditionalCommand(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	return trimmed == "[[" || strings.HasPrefix(trimmed, "[[ ")
+}
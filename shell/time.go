@@ -0,0 +1,183 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultTimeFormat is how time reports when TIMEFORMAT is unset
+const defaultTimeFormat string = "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS"
+
+// posixTimeFormat is the report of time -p
+const posixTimeFormat string = "real %2R\nuser %2U\nsys %2S"
+
+// cpuUsage is an amount of CPU time spent in user and system mode
+type cpuUsage struct {
+	user time.Duration
+	sys  time.Duration
+}
+
+// childrenUsage adds up the CPU time of the programs the shell has waited
+// for, as their ProcessState reports it
+var childrenUsage cpuUsage
+var childrenUsageMutex sync.Mutex
+
+// accountProcess adds the CPU time of a finished program to childrenUsage
+func accountProcess(state *os.ProcessState) {
+	if state == nil {
+		return
+	}
+	childrenUsageMutex.Lock()
+	defer childrenUsageMutex.Unlock()
+	childrenUsage.user += state.UserTime()
+	childrenUsage.sys += state.SystemTime()
+}
+
+// shellUsage returns the CPU time used by the shell itself, which is where
+// builtins run
+func shellUsage() cpuUsage {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return cpuUsage{}
+	}
+	return cpuUsage{
+		user: time.Duration(usage.Utime.Nano()),
+		sys:  time.Duration(usage.Stime.Nano()),
+	}
+}
+
+// totalUsage returns the CPU time of the shell and its children together
+func totalUsage() cpuUsage {
+	usage := shellUsage()
+	childrenUsageMutex.Lock()
+	defer childrenUsageMutex.Unlock()
+	usage.user += childrenUsage.user
+	usage.sys += childrenUsage.sys
+	return usage
+}
+
+// isTimeCommand reports whether command is a pipeline prefixed with time
+func isTimeCommand(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && fields[0] == "time"
+}
+
+// timePipeline runs the pipeline of `time [-p] pipeline` and then reports
+// the real, user and system time it took on the shell's stderr. The status
+// is that of the pipeline
+func timePipeline(command, PATH string, streams Streams) {
+	pipeline := strings.TrimSpace(strings.TrimSpace(command)[len("time"):])
+	format, set := getVar("TIMEFORMAT")
+	if !set {
+		format = defaultTimeFormat
+	}
+	for {
+		option, rest, _ := strings.Cut(pipeline, " ")
+		if option == "-p" {
+			format = posixTimeFormat
+		} else if option != "--" {
+			break
+		}
+		pipeline = strings.TrimSpace(rest)
+		if option == "--" {
+			break
+		}
+	}
+	start := time.Now()
+	before := totalUsage()
+	if pipeline != "" {
+		runPipeline(pipeline, PATH, streams)
+	}
+	after := totalUsage()
+	report := formatTimes(format, time.Since(start), after.user-before.user, after.sys-before.sys)
+	if report != "" {
+		fmt.Fprintln(streams.Stderr, report)
+	}
+}
+
+// formatTimes expands the % sequences of a TIMEFORMAT. %R, %U and %S are the
+// real, user and system time in seconds, with an optional precision of 0 to
+// 3 digits and an l for the MmS.FFFs form, %P is the CPU percentage and %%
+// a literal %
+func formatTimes(format string, real, user, sys time.Duration) string {
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		precision := 3
+		if format[j] >= '0' && format[j] <= '9' {
+			precision = min(int(format[j]-'0'), 3)
+			j++
+		}
+		long := false
+		if j < len(format) && format[j] == 'l' {
+			long = true
+			j++
+		}
+		if j == len(format) {
+			out.WriteString(format[i:])
+			break
+		}
+		var value time.Duration
+		switch format[j] {
+		case '%':
+			out.WriteByte('%')
+			i = j
+			continue
+		case 'P':
+			percent := 0.0
+			if real > 0 {
+				percent = float64(user+sys) / float64(real) * 100
+			}
+			fmt.Fprintf(&out, "%.2f", percent)
+			i = j
+			continue
+		case 'R':
+			value = real
+		case 'U':
+			value = user
+		case 'S':
+			value = sys
+		default:
+			// unknown sequences are left as they are
+			out.WriteString(format[i : j+1])
+			i = j
+			continue
+		}
+		out.WriteString(formatSeconds(value, precision, long))
+		i = j
+	}
+	return out.String()
+}
+
+// formatSeconds writes d in seconds with precision digits after the point,
+// as MmS.FFFs when long is set
+func formatSeconds(d time.Duration, precision int, long bool) string {
+	seconds := d.Seconds()
+	if !long {
+		return fmt.Sprintf("%.*f", precision, seconds)
+	}
+	minutes := int(seconds / 60)
+	seconds -= float64(minutes) * 60
+	return fmt.Sprintf("%dm%.*fs", minutes, precision, seconds)
+}
+
+// timesBuiltin prints the user and system time used by the shell and then
+// by its children
+func timesBuiltin(outputWriter io.Writer) int {
+	shell := shellUsage()
+	childrenUsageMutex.Lock()
+	children := childrenUsage
+	childrenUsageMutex.Unlock()
+	fmt.Fprintf(outputWriter, "%s %s\n", formatSeconds(shell.user, 3, true), formatSeconds(shell.sys, 3, true))
+	fmt.Fprintf(outputWriter, "%s %s\n", formatSeconds(children.user, 3, true), formatSeconds(children.sys, 3, true))
+	return 0
+}
@@ -0,0 +1,179 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// timeoutStatus is the exit status of a command that ran out of time
+const timeoutStatus int = 124
+
+var errInvalidDuration = errors.New("invalid time interval")
+
+// stopSignal is sent to the commands of a run whose context is done, and
+// killGracePeriod is how long they have to exit before SIGKILL follows
+var stopSignal syscall.Signal = syscall.SIGTERM
+var killGracePeriod time.Duration = 2 * time.Second
+
+// parseDuration reads a duration as timeout takes it, a number of seconds
+// that may have a fraction and an s, m, h or d suffix
+func parseDuration(spec string) (time.Duration, error) {
+	unit := time.Second
+	number := spec
+	if number != "" {
+		switch number[len(number)-1] {
+		case 's':
+			number = number[:len(number)-1]
+		case 'm':
+			unit = time.Minute
+			number = number[:len(number)-1]
+		case 'h':
+			unit = time.Hour
+			number = number[:len(number)-1]
+		case 'd':
+			unit = 24 * time.Hour
+			number = number[:len(number)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%s: %w", spec, errInvalidDuration)
+	}
+	return time.Duration(value * float64(unit)), nil
+}
+
+// runWithTimeout runs fn with the commands it starts limited to duration,
+// after which they get signal and then SIGKILL once grace has passed. A zero
+// duration sets no limit. The status is 124 if the time ran out
+func runWithTimeout(duration, grace time.Duration, signal syscall.Signal, fn func() int) int {
+	if duration <= 0 {
+		return fn()
+	}
+	parent := shellContext
+	ctx, cancel := context.WithTimeout(parent, duration)
+	defer cancel()
+	previousSignal, previousGrace := stopSignal, killGracePeriod
+	shellContext, stopSignal, killGracePeriod = ctx, signal, grace
+	defer func() {
+		shellContext, stopSignal, killGracePeriod = parent, previousSignal, previousGrace
+	}()
+	status := fn()
+	if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		return timeoutStatus
+	}
+	return status
+}
+
+// commandTimeout returns the limit CMDTMOUT sets on every pipeline, which
+// like TMOUT is a number of seconds
+func commandTimeout() time.Duration {
+	value := lookupVar("CMDTMOUT")
+	if value == "" {
+		return 0
+	}
+	duration, err := parseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// timeoutBuiltin runs a command, builtin or not, and stops it if it is still
+// running after the given duration
+func timeoutBuiltin(argsParts []string, streams Streams) int {
+	grace := killGracePeriod
+	signal := syscall.SIGTERM
+	preserveStatus := false
+	args := argsParts
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if args[0] == "--preserve-status" {
+			preserveStatus = true
+			args = args[1:]
+			continue
+		}
+		flags := args[0][1:]
+		args = args[1:]
+		for j, flag := range flags {
+			if flag != 'k' && flag != 's' {
+				fmt.Fprintln(streams.Stderr, "timeout: -"+string(flag)+": invalid option")
+				fmt.Fprintln(streams.Stderr, "timeout: usage: timeout [-k duration] [-s signal] [--preserve-status] duration command [arg ...]")
+				return 2
+			}
+			value := flags[j+1:]
+			if value == "" {
+				if len(args) == 0 {
+					fmt.Fprintln(streams.Stderr, "timeout: -"+string(flag)+": option requires an argument")
+					return 2
+				}
+				value = args[0]
+				args = args[1:]
+			}
+			if flag == 'k' {
+				var err error
+				if grace, err = parseDuration(value); err != nil {
+					fmt.Fprintln(streams.Stderr, "timeout: "+err.Error())
+					return timeoutStatus + 1
+				}
+			} else {
+				var ok bool
+				if signal, ok = parseSignal(value); !ok {
+					fmt.Fprintln(streams.Stderr, "timeout: "+value+": invalid signal")
+					return timeoutStatus + 1
+				}
+			}
+			break
+		}
+	}
+	if len(args) < 2 {
+		fmt.Fprintln(streams.Stderr, "timeout: usage: timeout [-k duration] [-s signal] [--preserve-status] duration command [arg ...]")
+		return timeoutStatus + 1
+	}
+	duration, err := parseDuration(args[0])
+	if err != nil {
+		fmt.Fprintln(streams.Stderr, "timeout: "+err.Error())
+		return timeoutStatus + 1
+	}
+	var status int
+	timedOut := runWithTimeout(duration, grace, signal, func() int {
+		status = runSimpleCommand(args[1], args[2:], streams)
+		return status
+	})
+	if preserveStatus {
+		return status
+	}
+	return timedOut
+}
+
+// runSimpleCommand runs an already expanded command, a builtin or a program
+// found in PATH, with the given streams
+func runSimpleCommand(name string, args []string, streams Streams) int {
+	if isBuiltin(name) {
+		return runBuiltin(shellContext, name, streams, args)
+	}
+	pathToExecutable, err := resolveCommand(name, pathDirectories())
+	if err != nil {
+		return reportCommandError(streams.Stderr, name, args, err)
+	}
+	cmd := newCommand(pathToExecutable, args...)
+	cmd.Args[0] = name
+	cmd.ExtraFiles = extraFiles()
+	cmd.Stdin = streams.Stdin
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
+	err = cmd.Run()
+	accountProcess(cmd.ProcessState)
+	status := exitStatus(err)
+	if err != nil && status == 126 && shellContext.Err() == nil {
+		reportCommandError(streams.Stderr, name, args, err)
+	}
+	return status
+}
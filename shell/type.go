@@ -0,0 +1,115 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// shellKeywords are the reserved words the shell's parser recognises itself
+var shellKeywords []string = []string{"[[", "]]", "coproc", "time"}
+
+// typeBuiltin describes how each name would be interpreted if used as a
+// command. Aliases come first, then keywords, builtins and finally files
+// found in PATH, which is the same order they are looked up when running one
+func typeBuiltin(argsParts []string, outputWriter, errWriter io.Writer, directories []string) int {
+	showAll := false
+	typeOnly := false
+	pathOnly := false
+	forcePath := false
+	names := argsParts
+	for len(names) > 0 && strings.HasPrefix(names[0], "-") && len(names[0]) > 1 {
+		if names[0] == "--" {
+			names = names[1:]
+			break
+		}
+		for _, flag := range names[0][1:] {
+			switch flag {
+			case 'a':
+				showAll = true
+			case 't':
+				typeOnly = true
+			case 'p':
+				pathOnly = true
+			case 'P':
+				forcePath = true
+			case 'f':
+				// there are no shell functions to suppress
+			default:
+				fmt.Fprintln(errWriter, "type: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "type: usage: type [-afptP] name [name ...]")
+				return 2
+			}
+		}
+		names = names[1:]
+	}
+	if len(names) == 0 {
+		if len(argsParts) == 0 {
+			fmt.Fprintln(errWriter, "type takes two arguments but none were given")
+			return 1
+		}
+		return 0
+	}
+	status := 0
+	for _, name := range names {
+		if !describeCommand(name, showAll, typeOnly, pathOnly, forcePath, outputWriter, directories) {
+			if !typeOnly && !pathOnly && !forcePath {
+				fmt.Fprintln(errWriter, name+": not found")
+			}
+			status = 1
+		}
+	}
+	return status
+}
+
+// describeCommand prints what name resolves to in the format chosen by the
+// type flags and reports whether it resolved to anything at all
+func describeCommand(name string, showAll, typeOnly, pathOnly, forcePath bool, outputWriter io.Writer, directories []string) bool {
+	found := false
+	describe := func(kind, description string) {
+		found = true
+		switch {
+		case typeOnly:
+			fmt.Fprintln(outputWriter, kind)
+		case pathOnly || forcePath:
+			// only files are reported when asking for paths
+		default:
+			fmt.Fprintln(outputWriter, name+description)
+		}
+	}
+	if !forcePath {
+		if value, ok := aliases[name]; ok {
+			describe("alias", " is aliased to `"+value+"'")
+		}
+		if (showAll || !found) && slices.Contains(shellKeywords, name) {
+			describe("keyword", " is a shell keyword")
+		}
+		if (showAll || !found) && isBuiltin(name) {
+			describe("builtin", typeFound)
+		}
+		if found && !showAll {
+			return true
+		}
+	}
+	var files []string
+	if strings.Contains(name, "/") {
+		if _, err := resolveCommand(name, directories); err == nil {
+			files = []string{name}
+		}
+	} else {
+		files = findInPath(name, directories, showAll)
+	}
+	for _, file := range files {
+		found = true
+		switch {
+		case typeOnly:
+			fmt.Fprintln(outputWriter, "file")
+		case pathOnly || forcePath:
+			fmt.Fprintln(outputWriter, file)
+		default:
+			fmt.Fprintln(outputWriter, name+" is "+file)
+		}
+	}
+	return found
+}
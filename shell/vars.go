@@ -0,0 +1,445 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var errReadonly = errors.New("readonly variable")
+var errBadSubscript = errors.New("bad array subscript")
+var errAssocSubscript = errors.New("must use subscript when assigning associative array")
+
+// shellVars holds the variables set in the shell that are not exported.
+// Exported variables live in the process environment itself so that the
+// commands the shell starts inherit them without any extra plumbing
+var shellVars map[string]string = map[string]string{}
+
+// getVar looks up a shell variable, computing the special ones that reflect
+// the shell's own state. An array stands for its element 0
+func getVar(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(lastExitStatus), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	}
+	if array, ok := getArray(name); ok {
+		return array.get("0")
+	}
+	if value, ok := shellVars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func lookupVar(name string) string {
+	value, _ := getVar(name)
+	return value
+}
+
+// setVar assigns to an exported variable in place and creates everything
+// else as a shell variable
+func setVar(name, value string) error {
+	return storeVar(name, value, false)
+}
+
+// storeVar assigns value to name, or appends it to the current value, after
+// applying the attributes given to the variable by declare
+func storeVar(name, value string, appending bool) error {
+	attrs := shellVarAttrs[name]
+	if attrs.readonly {
+		return fmt.Errorf("%s: %w", name, errReadonly)
+	}
+	old, _ := getVar(name)
+	value, err := attrs.convert(old, value, appending)
+	if err != nil {
+		return err
+	}
+	if array, ok := shellArrays[name]; ok {
+		array.set("0", value)
+		return nil
+	}
+	if _, exported := os.LookupEnv(name); exported {
+		os.Setenv(name, value)
+		return nil
+	}
+	shellVars[name] = value
+	return nil
+}
+
+// removeScalar forgets the plain value of name, as when it becomes an array
+func removeScalar(name string) {
+	delete(shellVars, name)
+	os.Unsetenv(name)
+}
+
+// isAssignmentError reports whether err came from assigning a variable
+func isAssignmentError(err error) bool {
+	for _, target := range []error{errReadonly, errBadSubscript, errAssocSubscript, errArithmetic, errDivisionByZero, errArithRecursion} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, char := range name {
+		isLetter := char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+		if !isLetter && (i == 0 || char < '0' || char > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// assignment is a NAME=value word. It may also be NAME+=value to append,
+// NAME[subscript]=value to set an array element or NAME=(...) to assign a
+// whole array, in which case value is the text between the parentheses
+type assignment struct {
+	name         string
+	subscript    string
+	hasSubscript bool
+	appending    bool
+	compound     bool
+	value        string
+}
+
+// parseAssignment reports whether word has the form of a variable
+// assignment. A value in parentheses is only taken as a list of array
+// elements when compound is set, since quoted parentheses are plain text
+func parseAssignment(word string, compound bool) (assignment, bool) {
+	lhs, value, found := strings.Cut(word, "=")
+	if !found {
+		return assignment{}, false
+	}
+	a := assignment{value: value}
+	if strings.HasSuffix(lhs, "+") {
+		a.appending = true
+		lhs = lhs[:len(lhs)-1]
+	}
+	if open := strings.IndexByte(lhs, '['); open > 0 && strings.HasSuffix(lhs, "]") {
+		a.subscript = lhs[open+1 : len(lhs)-1]
+		a.hasSubscript = true
+		lhs = lhs[:open]
+	}
+	if !isValidVarName(lhs) {
+		return assignment{}, false
+	}
+	a.name = lhs
+	if compound && !a.hasSubscript && len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')' {
+		a.compound = true
+		a.value = value[1 : len(value)-1]
+	}
+	return a, true
+}
+
+func (a assignment) apply() error {
+	if shellVarAttrs[a.name].readonly {
+		return fmt.Errorf("%s: %w", a.name, errReadonly)
+	}
+	switch {
+	case a.compound:
+		return assignCompound(a.name, a.value, a.appending)
+	case a.hasSubscript:
+		return setArrayElement(a.name, a.subscript, a.value, a.appending)
+	}
+	return storeVar(a.name, a.value, a.appending)
+}
+
+// expandParameter expands the $name, ${name} or special parameter starting
+// at input[i] (which must be '$'). It returns the value along with the index
+// of the last byte that was consumed. A '$' that doesn't start a parameter
+// is kept as is
+func expandParameter(input string, i int) (string, int) {
+	fields, end := expandParameterFields(input, i)
+	return strings.Join(fields, " "), end
+}
+
+// expandParameterFields is expandParameter for callers that build words:
+// ${name[@]} expands to one field per element, which may be none at all
+func expandParameterFields(input string, i int) ([]string, int) {
+	if i+1 >= len(input) {
+		return []string{"$"}, i
+	}
+	next := input[i+1]
+	switch {
+	case next == '{':
+		depth := 0
+		for end := i + 1; end < len(input); end++ {
+			switch input[end] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return expandBraced(input[i+2 : end]), end
+				}
+			}
+		}
+		return []string{"$"}, i
+	case next == '?' || next == '$':
+		return []string{lookupVar(string(next))}, i + 1
+	}
+	end := i + 1
+	for end < len(input) && isValidVarName(input[i+1:end+1]) {
+		end++
+	}
+	if end == i+1 {
+		return []string{"$"}, i
+	}
+	return []string{lookupVar(input[i+1 : end])}, end - 1
+}
+
+// expandString expands every parameter in s, as in an array subscript
+func expandString(s string) string {
+	var res strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			res.WriteByte(s[i])
+			continue
+		}
+		var value string
+		value, i = expandParameter(s, i)
+		res.WriteString(value)
+	}
+	return res.String()
+}
+
+// splitParameter separates the name and optional [subscript] at the start
+// of the inside of ${...} from whatever follows them
+func splitParameter(body string) (string, string) {
+	end := 0
+	if body != "" && (body[0] == '?' || body[0] == '$') {
+		end = 1
+	}
+	for end < len(body) && isArithWordChar(body[end]) {
+		end++
+	}
+	if end < len(body) && body[end] == '[' {
+		depth := 0
+		for j := end; j < len(body); j++ {
+			if body[j] == '[' {
+				depth++
+			} else if body[j] == ']' {
+				depth--
+				if depth == 0 {
+					return body[:j+1], body[j+1:]
+				}
+			}
+		}
+	}
+	return body[:end], body[end:]
+}
+
+// cutSubscript splits name[subscript] into its parts
+func cutSubscript(param string) (string, string, bool) {
+	open := strings.IndexByte(param, '[')
+	if open <= 0 || !strings.HasSuffix(param, "]") {
+		return param, "", false
+	}
+	return param[:open], param[open+1 : len(param)-1], true
+}
+
+// parameterValues returns what a parameter expands to. For name[@] and
+// name[*] that is every element and the returned byte is '@' or '*',
+// otherwise it is the single value, or nothing when it is unset
+func parameterValues(param string) ([]string, byte) {
+	name, subscript, hasSubscript := cutSubscript(param)
+	if !hasSubscript {
+		if value, ok := getVar(name); ok {
+			return []string{value}, 0
+		}
+		return nil, 0
+	}
+	if subscript == "@" || subscript == "*" {
+		if array, ok := getArray(name); ok {
+			return array.values(), subscript[0]
+		}
+		if value, ok := getVar(name); ok {
+			return []string{value}, subscript[0]
+		}
+		return []string{}, subscript[0]
+	}
+	subscript = expandString(subscript)
+	array, ok := getArray(name)
+	if !ok {
+		// a plain variable is an array with just element 0
+		if index, err := evalArithmetic(subscript); err == nil && index == 0 {
+			return parameterValues(name)
+		}
+		return nil, 0
+	}
+	key, err := resolveSubscript(array, subscript)
+	if err != nil {
+		return nil, 0
+	}
+	if value, ok := array.get(key); ok {
+		return []string{value}, 0
+	}
+	return nil, 0
+}
+
+// joinFields turns the values of a parameter into the fields it expands
+// to. The elements of name[*] are joined by the first character of IFS
+func joinFields(values []string, mode byte) []string {
+	switch mode {
+	case '@':
+		return values
+	case '*':
+		separator := " "
+		if ifs, ok := getVar("IFS"); ok {
+			separator = ifs[:min(1, len(ifs))]
+		}
+		return []string{strings.Join(values, separator)}
+	}
+	return []string{strings.Join(values, "")}
+}
+
+// sliceValues applies the :offset:length of ${name:offset:length}, which
+// counts characters of a value or elements of name[@]. Negative offsets
+// count back from the end, as does a negative length for a value
+func sliceValues(values []string, mode byte, spec string) []string {
+	offsetExpr, lengthExpr, hasLength := strings.Cut(spec, ":")
+	offset, err := evalArithmetic(expandString(offsetExpr))
+	if err != nil {
+		return []string{""}
+	}
+	bounds := func(size int) (int, int, bool) {
+		start := int(offset)
+		if start < 0 {
+			start += size
+		}
+		if start < 0 || start > size {
+			return 0, 0, false
+		}
+		end := size
+		if hasLength {
+			length, err := evalArithmetic(expandString(lengthExpr))
+			if err != nil {
+				return 0, 0, false
+			}
+			if length < 0 {
+				end = size + int(length)
+			} else {
+				end = min(size, start+int(length))
+			}
+		}
+		return start, max(start, end), true
+	}
+	if mode != 0 {
+		start, end, ok := bounds(len(values))
+		if !ok {
+			return joinFields([]string{}, mode)
+		}
+		return joinFields(values[start:end], mode)
+	}
+	runes := []rune(strings.Join(values, ""))
+	start, end, ok := bounds(len(runes))
+	if !ok {
+		return []string{""}
+	}
+	return []string{string(runes[start:end])}
+}
+
+// expandBraced expands the inside of ${...}: a name with an optional
+// [subscript], ${#...} for lengths, ${!name[@]} for the subscripts of an
+// array and ${...:offset:length} for substrings and array slices
+func expandBraced(body string) []string {
+	switch {
+	case len(body) > 1 && body[0] == '#':
+		values, mode := parameterValues(body[1:])
+		if mode != 0 {
+			return []string{strconv.Itoa(len(values))}
+		}
+		return []string{strconv.Itoa(utf8.RuneCountInString(strings.Join(values, "")))}
+	case len(body) > 1 && body[0] == '!':
+		param, rest := splitParameter(body[1:])
+		name, subscript, _ := cutSubscript(param)
+		if rest == "" && (subscript == "@" || subscript == "*") {
+			return joinFields(arrayKeys(name), subscript[0])
+		}
+		// ${!name} expands the variable whose name is the value of name
+		values, _ := parameterValues(param)
+		indirect, _ := parameterValues(strings.Join(values, ""))
+		return joinFields(indirect, 0)
+	}
+	param, rest := splitParameter(body)
+	values, mode := parameterValues(param)
+	if len(rest) > 1 && rest[0] == ':' && strings.IndexByte("-=+?", rest[1]) == -1 {
+		return sliceValues(values, mode, rest[1:])
+	}
+	if rest != "" {
+		return []string{""}
+	}
+	return joinFields(values, mode)
+}
+
+// applyAssignments consumes the NAME=value words at the start of a command.
+// With nothing left to run they become shell variables, otherwise they are
+// only placed in the environment of the command and the previous values are
+// restored by the returned function
+func applyAssignments(words []shellWord) (string, []string, func(), error) {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.text
+	}
+	assignments := 0
+	for assignments < len(words) {
+		if _, ok := parseAssignment(words[assignments].text, words[assignments].compound); !ok {
+			break
+		}
+		assignments++
+	}
+	if assignments == 0 {
+		if len(texts) == 0 {
+			return "", nil, func() {}, nil
+		}
+		return texts[0], texts[1:], func() {}, nil
+	}
+	if assignments == len(words) {
+		for _, word := range words {
+			a, _ := parseAssignment(word.text, word.compound)
+			if err := a.apply(); err != nil {
+				return "", nil, func() {}, err
+			}
+		}
+		return "", nil, func() {}, nil
+	}
+	type savedVar struct {
+		name    string
+		value   string
+		existed bool
+	}
+	saved := make([]savedVar, 0, assignments)
+	restore := func() {
+		for i := len(saved) - 1; i >= 0; i-- {
+			if saved[i].existed {
+				os.Setenv(saved[i].name, saved[i].value)
+			} else {
+				os.Unsetenv(saved[i].name)
+			}
+		}
+	}
+	for _, word := range words[:assignments] {
+		a, _ := parseAssignment(word.text, word.compound)
+		if shellVarAttrs[a.name].readonly {
+			restore()
+			return "", nil, func() {}, fmt.Errorf("%s: %w", a.name, errReadonly)
+		}
+		previous, existed := os.LookupEnv(a.name)
+		saved = append(saved, savedVar{a.name, previous, existed})
+		if a.appending {
+			a.value = lookupVar(a.name) + a.value
+		}
+		os.Setenv(a.name, a.value)
+	}
+	return texts[assignments], texts[assignments+1:], restore, nil
+}
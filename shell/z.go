@@ -0,0 +1,267 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTotalRank bounds the sum of all ranks in the frecency database. Once
+// it is exceeded every rank is aged so old directories eventually drop out
+const maxTotalRank float64 = 9000
+
+// frecentDir is one line of the frecency database: how often a directory was
+// visited (rank) and when it was last visited
+type frecentDir struct {
+	path      string
+	rank      float64
+	lastVisit int64
+}
+
+// frecencyDataFile is where visited directories are remembered between
+// sessions, following the XDG base directory spec
+func frecencyDataFile() string {
+	dataHome := lookupVar("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(expandTilde("~"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "goshell", "z")
+}
+
+// readFrecencyData loads the database. Each line holds path|rank|timestamp
+// and lines that can't be parsed are skipped
+func readFrecencyData() []frecentDir {
+	f, err := os.Open(frecencyDataFile())
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	entries := make([]frecentDir, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 {
+			continue
+		}
+		rank, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		lastVisit, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, frecentDir{path: fields[0], rank: rank, lastVisit: lastVisit})
+	}
+	return entries
+}
+
+// writeFrecencyData replaces the database through a temporary file so a
+// concurrently running shell never reads a half written one
+func writeFrecencyData(entries []frecentDir) error {
+	dataFile := frecencyDataFile()
+	if err := os.MkdirAll(filepath.Dir(dataFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dataFile), ".z-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s|%s|%d\n", entry.path, strconv.FormatFloat(entry.rank, 'f', -1, 64), entry.lastVisit)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), dataFile)
+}
+
+// recordDirectoryVisit bumps the rank of the current directory in the
+// frecency database. It is called after every successful directory change
+func recordDirectoryVisit() {
+	dir := currentDirectory()
+	if dir == "" || dir == lookupVar("HOME") {
+		return
+	}
+	now := time.Now().Unix()
+	entries := readFrecencyData()
+	found := false
+	totalRank := 0.0
+	for i := range entries {
+		if entries[i].path == dir {
+			entries[i].rank++
+			entries[i].lastVisit = now
+			found = true
+		}
+		totalRank += entries[i].rank
+	}
+	if !found {
+		entries = append(entries, frecentDir{path: dir, rank: 1, lastVisit: now})
+		totalRank++
+	}
+	if totalRank > maxTotalRank {
+		aged := entries[:0]
+		for _, entry := range entries {
+			entry.rank *= 0.99
+			if entry.rank >= 1 {
+				aged = append(aged, entry)
+			}
+		}
+		entries = aged
+	}
+	writeFrecencyData(entries)
+}
+
+// frecency weighs how often a directory was visited by how recently
+func frecency(entry frecentDir, now int64) float64 {
+	age := now - entry.lastVisit
+	switch {
+	case age < 3600:
+		return entry.rank * 4
+	case age < 86400:
+		return entry.rank * 2
+	case age < 604800:
+		return entry.rank / 2
+	default:
+		return entry.rank / 4
+	}
+}
+
+// matchesTerms reports whether every term appears in path, in order
+func matchesTerms(path string, terms []string, ignoreCase bool) bool {
+	if ignoreCase {
+		path = strings.ToLower(path)
+	}
+	for _, term := range terms {
+		if ignoreCase {
+			term = strings.ToLower(term)
+		}
+		idx := strings.Index(path, term)
+		if idx == -1 {
+			return false
+		}
+		path = path[idx+len(term):]
+	}
+	return true
+}
+
+type rankedDir struct {
+	path  string
+	score float64
+}
+
+// rankFrecentDirs returns the existing directories matching terms, best
+// first. Case sensitive matches are preferred and case is only ignored when
+// there are none
+func rankFrecentDirs(terms []string, byRank, byTime bool) []rankedDir {
+	now := time.Now().Unix()
+	entries := readFrecencyData()
+	var ranked []rankedDir
+	for _, ignoreCase := range []bool{false, true} {
+		ranked = make([]rankedDir, 0)
+		for _, entry := range entries {
+			if !matchesTerms(entry.path, terms, ignoreCase) {
+				continue
+			}
+			if info, err := os.Stat(entry.path); err != nil || !info.IsDir() {
+				continue
+			}
+			score := frecency(entry, now)
+			switch {
+			case byRank:
+				score = entry.rank
+			case byTime:
+				score = float64(entry.lastVisit - now)
+			}
+			ranked = append(ranked, rankedDir{path: entry.path, score: score})
+		}
+		if len(ranked) > 0 {
+			break
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	return ranked
+}
+
+func zBuiltin(argsParts []string, outputWriter, errWriter io.Writer) int {
+	list := false
+	byRank := false
+	byTime := false
+	terms := argsParts
+	for len(terms) > 0 && strings.HasPrefix(terms[0], "-") && len(terms[0]) > 1 {
+		if terms[0] == "--" {
+			terms = terms[1:]
+			break
+		}
+		for _, flag := range terms[0][1:] {
+			switch flag {
+			case 'l':
+				list = true
+			case 'r':
+				byRank = true
+			case 't':
+				byTime = true
+			default:
+				fmt.Fprintln(errWriter, "z: -"+string(flag)+": invalid option")
+				fmt.Fprintln(errWriter, "z: usage: z [-lrt] [dir ...]")
+				return 2
+			}
+		}
+		terms = terms[1:]
+	}
+	ranked := rankFrecentDirs(terms, byRank, byTime)
+	if list || len(terms) == 0 {
+		// best match last, right above the prompt
+		for i := len(ranked) - 1; i >= 0; i-- {
+			fmt.Fprintf(outputWriter, "%-10s %s\n", strconv.FormatFloat(ranked[i].score, 'f', 1, 64), ranked[i].path)
+		}
+		return 0
+	}
+	current := currentDirectory()
+	for _, candidate := range ranked {
+		if candidate.path == current {
+			continue
+		}
+		if err := changeDirectory(candidate.path, false); err != nil {
+			fmt.Fprintln(errWriter, "z: "+candidate.path+": "+describeChdirError(err))
+			return 1
+		}
+		return 0
+	}
+	return 1
+}
+
+// completeFrecentDirs offers completions for the argument of z. Readline can
+// only append to what was typed, so each ranked directory is offered as the
+// rest of the last path component that starts with the typed word
+func completeFrecentDirs(word string) [][]rune {
+	completions := make([][]rune, 0)
+	seen := map[string]bool{}
+	for _, candidate := range rankFrecentDirs(nil, false, false) {
+		components := strings.Split(candidate.path, "/")
+		for i := len(components) - 1; i >= 0; i-- {
+			if components[i] == "" || !strings.HasPrefix(components[i], word) {
+				continue
+			}
+			suffix := components[i][len(word):]
+			if !seen[suffix] {
+				seen[suffix] = true
+				completions = append(completions, []rune(suffix+" "))
+			}
+			break
+		}
+	}
+	return completions
+}